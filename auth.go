@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/RealImage/QLedger/middlewares"
+	"github.com/RealImage/QLedger/models"
+)
+
+// newAuthenticator returns the authenticator of the API requests,
+// for the authentication scheme in `LEDGER_AUTH_SCHEME`.
+// The requests should also have a verified client certificate if `LEDGER_TLS_CLIENT_CA_FILE` is set.
+func newAuthenticator(db *sql.DB) middlewares.Authenticator {
+	auth := newSchemeAuthenticator(db)
+	if _, ok := auth.(*middlewares.CertificateAuth); !ok && os.Getenv("LEDGER_TLS_CLIENT_CA_FILE") != "" {
+		return middlewares.NewCertificateRequiredAuth(auth)
+	}
+	return auth
+}
+
+// newSchemeAuthenticator returns the authenticator of the authentication scheme in `LEDGER_AUTH_SCHEME`
+func newSchemeAuthenticator(db *sql.DB) middlewares.Authenticator {
+	switch scheme := os.Getenv("LEDGER_AUTH_SCHEME"); scheme {
+	case "", "token":
+		return newTokenAuth(db)
+	case "hmac":
+		return newHMACAuth()
+	case "jwt":
+		return newJWTAuth()
+	case "mtls":
+		return newCertificateAuth()
+	default:
+		log.Fatal("Unknown authentication scheme: ", scheme)
+	}
+	return nil
+}
+
+// newTokenAuth returns the authenticator of the `LEDGER_AUTH_TOKEN` and the API tokens.
+// The API tokens are loaded from the file if configured, otherwise from the database.
+func newTokenAuth(db *sql.DB) middlewares.Authenticator {
+	var tokenStore models.TokenStore
+	if tokensFile := os.Getenv("LEDGER_AUTH_TOKENS_FILE"); tokensFile != "" {
+		tokenFile, err := models.LoadTokenFile(tokensFile)
+		if err != nil {
+			log.Fatal("Unable to load the API tokens file:", err)
+		}
+		tokenStore = tokenFile
+	} else {
+		tokenDB := models.NewTokenDB(db)
+		tokenStore = &tokenDB
+	}
+	if os.Getenv("LEDGER_AUTH_TOKEN") == "" {
+		hasTokens, aerr := tokenStore.HasTokens()
+		if aerr != nil {
+			log.Fatal("Unable to check the API tokens:", aerr)
+		}
+		if !hasTokens {
+			log.Fatal("Cannot start the server. Authentication token is not set!! Please set LEDGER_AUTH_TOKEN or create an API token")
+		}
+		log.Println("LEDGER_AUTH_TOKEN is not set. Only the API tokens are allowed")
+	}
+	return middlewares.NewTokenAuth(tokenStore)
+}
+
+// newHMACAuth returns the authenticator of the requests signed with the keys in `LEDGER_HMAC_KEYS_FILE`
+func newHMACAuth() middlewares.Authenticator {
+	keysFile := os.Getenv("LEDGER_HMAC_KEYS_FILE")
+	if keysFile == "" {
+		log.Fatal("Cannot start the server. HMAC keys file is not set!! Please set LEDGER_HMAC_KEYS_FILE")
+	}
+	keys, err := models.LoadHMACKeyFile(keysFile)
+	if err != nil {
+		log.Fatal("Unable to load the HMAC keys file:", err)
+	}
+
+	skew := middlewares.DefaultHMACSkew
+	if value := os.Getenv("LEDGER_HMAC_SKEW"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			log.Fatal("Invalid LEDGER_HMAC_SKEW: ", value)
+		}
+		skew = time.Duration(seconds) * time.Second
+	}
+	maxBodyBytes := int64(middlewares.DefaultHMACMaxBodyBytes)
+	if value := os.Getenv("LEDGER_HMAC_MAX_BODY_BYTES"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			log.Fatal("Invalid LEDGER_HMAC_MAX_BODY_BYTES: ", value)
+		}
+		maxBodyBytes = limit
+	}
+	return middlewares.NewHMACAuth(keys, skew).WithMaxBodyBytes(maxBodyBytes)
+}
+
+// newJWTAuth returns the authenticator of the JWT bearer tokens, verified using the JSON Web Key Set
+// in `LEDGER_JWT_JWKS_FILE` or `LEDGER_JWT_JWKS`
+func newJWTAuth() middlewares.Authenticator {
+	jwks := []byte(os.Getenv("LEDGER_JWT_JWKS"))
+	if jwksFile := os.Getenv("LEDGER_JWT_JWKS_FILE"); jwksFile != "" {
+		var err error
+		jwks, err = ioutil.ReadFile(jwksFile)
+		if err != nil {
+			log.Fatal("Unable to read the JWKS file:", err)
+		}
+	}
+	if len(jwks) == 0 {
+		log.Fatal("Cannot start the server. JWT keys are not set!! Please set LEDGER_JWT_JWKS_FILE or LEDGER_JWT_JWKS")
+	}
+	keys, err := middlewares.ParseJWKS(jwks)
+	if err != nil {
+		log.Fatal("Unable to parse the JWKS:", err)
+	}
+
+	config := middlewares.JWTConfig{
+		Issuer:        os.Getenv("LEDGER_JWT_ISSUER"),
+		Audience:      os.Getenv("LEDGER_JWT_AUDIENCE"),
+		ScopesClaim:   os.Getenv("LEDGER_JWT_SCOPES_CLAIM"),
+		AccountsClaim: os.Getenv("LEDGER_JWT_ACCOUNTS_CLAIM"),
+		Leeway:        time.Minute,
+	}
+	if config.ScopesClaim == "" {
+		config.ScopesClaim = "scope"
+	}
+	if config.AccountsClaim == "" {
+		config.AccountsClaim = "ledger_accounts"
+	}
+	return middlewares.NewJWTAuth(keys, config)
+}
+
+// newCertificateAuth returns the authenticator of the TLS client certificates,
+// with their identities in `LEDGER_TLS_CLIENTS_FILE`
+func newCertificateAuth() middlewares.Authenticator {
+	if os.Getenv("LEDGER_TLS_CLIENT_CA_FILE") == "" {
+		log.Fatal("Cannot start the server. Client CA is not set!! Please set LEDGER_TLS_CLIENT_CA_FILE")
+	}
+	clientsFile := os.Getenv("LEDGER_TLS_CLIENTS_FILE")
+	if clientsFile == "" {
+		log.Fatal("Cannot start the server. Client identities are not set!! Please set LEDGER_TLS_CLIENTS_FILE")
+	}
+	certificates, err := models.LoadClientCertificateFile(clientsFile)
+	if err != nil {
+		log.Fatal("Unable to load the client identities file:", err)
+	}
+	return middlewares.NewCertificateAuth(certificates)
+}
@@ -0,0 +1,71 @@
+// Package client provides the helpers for the clients of the QLedger APIs
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of the HMAC signed requests
+const (
+	HeaderKey       = "X-Ledger-Key"
+	HeaderTimestamp = "X-Ledger-Timestamp"
+	HeaderNonce     = "X-Ledger-Nonce"
+	HeaderSignature = "X-Ledger-Signature"
+)
+
+// BodyDigest returns the hex encoded SHA-256 digest of the request body
+func BodyDigest(body []byte) string {
+	digest := sha256.Sum256(body)
+	return hex.EncodeToString(digest[:])
+}
+
+// StringToSign returns the string signed by the HMAC signature of a request.
+// It has the method, the path with query, the unix timestamp, the nonce and the body digest in separate lines.
+func StringToSign(method string, uri string, timestamp string, nonce string, bodyDigest string) string {
+	return strings.Join([]string{method, uri, timestamp, nonce, bodyDigest}, "\n")
+}
+
+// Signature returns the hex encoded HMAC-SHA256 signature of the string using the secret
+func Signature(secret string, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign signs the request with the secret of the named key, by setting the signature headers.
+// The request body is read and replaced to compute its digest.
+func Sign(req *http.Request, key string, secret string) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+	stringToSign := StringToSign(req.Method, req.URL.RequestURI(), timestamp, nonceHex, BodyDigest(body))
+
+	req.Header.Set(HeaderKey, key)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonceHex)
+	req.Header.Set(HeaderSignature, Signature(secret, stringToSign))
+	return nil
+}
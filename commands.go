@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/RealImage/QLedger/models"
+)
+
+// runCommand runs the QLedger subcommand with the given arguments
+func runCommand(db *sql.DB, name string, args []string) {
+	switch name {
+	case "import":
+		importCommand(db, args)
+	case "export":
+		exportCommand(db, args)
+	case "token":
+		tokenCommand(db, args)
+	default:
+		log.Fatal("Unknown command: ", name)
+	}
+}
+
+// importCommand imports the accounts and transactions from the NDJSON files
+// and writes the result of each record to the standard output as NDJSON
+func importCommand(db *sql.DB, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	batchSize := flags.Int("batch-size", models.BulkBatchSize, "number of records imported in a single DB transaction")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		log.Fatal("Usage: QLedger import [-batch-size N] FILE...")
+	}
+
+	importer := models.NewBulkImporter(db, *batchSize).WithSchemas(loadDataSchemas())
+	encoder := json.NewEncoder(os.Stdout)
+	for _, filename := range flags.Args() {
+		file, err := os.Open(filename)
+		if err != nil {
+			log.Fatal("Error opening file:", err)
+		}
+		log.Println("Importing records from file:", filename)
+		counts := make(map[string]int)
+		audit := &models.AuditInfo{Identity: "command", Endpoint: "import " + filename}
+		err = importer.WithAudit(audit).Import(file, func(results []*models.BulkResult) error {
+			for _, result := range results {
+				counts[result.Status]++
+				if err := encoder.Encode(result); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		file.Close()
+		if err != nil {
+			log.Fatal("Error importing file:", err)
+		}
+		log.Printf("Imported records from file: %v %v", filename, counts)
+	}
+}
+
+// exportCommand exports all the accounts and transactions as NDJSON to a file or the standard output
+func exportCommand(db *sql.DB, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the export, instead of the standard output")
+	flags.Parse(args)
+
+	w := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatal("Error creating file:", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	exporter := models.NewExporter(db)
+	writer := bufio.NewWriter(w)
+	err := exporter.Export(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		log.Fatal("Error exporting ledger:", err)
+	}
+	log.Println("Exported ledger successfully")
+}
+
+// tokenCommand creates or deletes the named API tokens in the database.
+// The created token is written to the standard output, as only its hash is stored.
+func tokenCommand(db *sql.DB, args []string) {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	scopes := flags.String("scopes", "", "comma separated scopes of the token")
+	accounts := flags.String("accounts", "", "comma separated patterns of the accounts the token is restricted to")
+	if len(args) > 0 {
+		flags.Parse(args[1:])
+	}
+	if len(args) == 0 || flags.NArg() != 1 {
+		log.Fatal("Usage: QLedger token create -scopes SCOPE,... [-accounts PATTERN,...] NAME | QLedger token delete NAME")
+	}
+
+	tokenDB := models.NewTokenDB(db)
+	name := flags.Arg(0)
+	switch args[0] {
+	case "create":
+		var tokenScopes []string
+		if *scopes != "" {
+			tokenScopes = strings.Split(*scopes, ",")
+		}
+		var tokenAccounts models.AccountPatterns
+		if *accounts != "" {
+			tokenAccounts = strings.Split(*accounts, ",")
+		}
+		token, aerr := tokenDB.CreateToken(name, tokenScopes, tokenAccounts)
+		if aerr != nil {
+			log.Fatal("Error creating token:", aerr)
+		}
+		fmt.Println(token)
+		log.Println("Created token:", name)
+	case "delete":
+		aerr := tokenDB.DeleteToken(name)
+		if aerr != nil {
+			log.Fatal("Error deleting token:", aerr)
+		}
+		log.Println("Deleted token:", name)
+	default:
+		log.Fatal("Unknown token command: ", args[0])
+	}
+}
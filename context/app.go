@@ -2,9 +2,17 @@ package context
 
 import (
 	"database/sql"
+
+	"github.com/RealImage/QLedger/models"
 )
 
 // AppContext provides the context to the app components such as controllers, jobs, etc.,
 type AppContext struct {
 	DB *sql.DB
+	// FrozenKeys are the keys of the transaction data which can't be changed once they are set
+	FrozenKeys *models.FrozenKeys
+	// Schemas are the JSON Schemas validating the data of the accounts and transactions
+	Schemas *models.DataSchemas
+	// BulkMaxBytes is the limit of the size of a bulk import request
+	BulkMaxBytes int64
 }
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/middlewares"
+	"github.com/RealImage/QLedger/models"
+)
+
+// accountFilter returns the filter of the accounts the caller of the request is restricted to
+func accountFilter(r *http.Request) *models.AccountFilter {
+	return middlewares.RequestIdentity(r).AccountFilter()
+}
+
+// checkAccounts returns an error if any of the accounts is not allowed for the caller of the request
+func checkAccounts(r *http.Request, ids ...string) ledgerError.ApplicationError {
+	accounts := accountFilter(r)
+	for _, id := range ids {
+		if !accounts.Allows(id) {
+			return models.AccountForbiddenError(id)
+		}
+	}
+	return nil
+}
+
+// lineAccounts returns the account IDs of the transaction lines
+func lineAccounts(lines []*models.TransactionLine) []string {
+	ids := make([]string, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.AccountID)
+	}
+	return ids
+}
+
+// lineResultAccounts returns the account IDs of the transaction lines in the search result format
+func lineResultAccounts(lines []*models.TransactionLineResult) []string {
+	ids := make([]string, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.AccountID)
+	}
+	return ids
+}
@@ -2,13 +2,13 @@ package controllers
 
 import (
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"regexp"
 
 	ledgerContext "github.com/RealImage/QLedger/context"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/middlewares"
 	"github.com/RealImage/QLedger/models"
 )
 
@@ -17,7 +17,7 @@ func GetAccounts(w http.ResponseWriter, r *http.Request, context *ledgerContext.
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Println("Error reading payload:", err)
-		w.WriteHeader(http.StatusBadRequest)
+		ledgerError.WriteResponse(w, models.PayloadInvalidError(err))
 		return
 	}
 	defer r.Body.Close()
@@ -26,26 +26,114 @@ func GetAccounts(w http.ResponseWriter, r *http.Request, context *ledgerContext.
 	engine, aerr := models.NewSearchEngine(context.DB, models.SearchNamespaceAccounts)
 	if aerr != nil {
 		log.Println("Error while creating Search Engine:", aerr)
-		w.WriteHeader(http.StatusInternalServerError)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
+	engine.RestrictAccounts(accountFilter(r))
 	results, aerr := engine.Query(query)
 	if aerr != nil {
 		log.Println("Error while querying:", aerr)
-		switch aerr.ErrorCode() {
-		case "search.query.invalid":
-			w.WriteHeader(http.StatusBadRequest)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		log.Println("Error while parsing results:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+	return
+}
+
+// GetAccount returns the account with the ID in the route,
+// with the balance as of the optional `as_of` timestamp
+// and the data of the optional `as_of_version` version
+func GetAccount(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+	if aerr := checkAccounts(r, id); aerr != nil {
+		log.Println("Account is not allowed:", id)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	asOf := r.URL.Query().Get("as_of")
+	if aerr := models.ValidateTimestamp(asOf); aerr != nil {
+		log.Println("Invalid as_of timestamp:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	asOfVersion, aerr := parseVersion(r.URL.Query().Get("as_of_version"))
+	if aerr != nil {
+		log.Println("Invalid as_of_version:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	accountsDB := models.NewAccountDB(context.DB)
+	account, aerr := accountsDB.GetResultByID(id, asOf)
+	if aerr != nil {
+		log.Println("Error while getting account:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	if asOfVersion != 0 {
+		version, aerr := accountsDB.GetVersion(id, asOfVersion)
+		if aerr != nil {
+			log.Println("Error while getting account version:", aerr)
+			ledgerError.WriteResponse(w, aerr)
 			return
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
+		}
+		account.Data = version.Data
+		account.Version = version.Version
+	}
+	setETag(w, account.Version)
+
+	data, err := json.Marshal(account)
+	if err != nil {
+		log.Println("Error while parsing account:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+	return
+}
+
+// GetAccountStatement returns the statement of the account with the ID in the route
+// for the period between the optional `from` and `to` timestamps
+func GetAccountStatement(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+	if aerr := checkAccounts(r, id); aerr != nil {
+		log.Println("Account is not allowed:", id)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	for _, timestamp := range []string{from, to} {
+		if aerr := models.ValidateTimestamp(timestamp); aerr != nil {
+			log.Println("Invalid statement period:", aerr)
+			ledgerError.WriteResponse(w, aerr)
 			return
 		}
 	}
 
-	data, err := json.Marshal(results)
+	accountsDB := models.NewAccountDB(context.DB)
+	statement, aerr := accountsDB.GetStatement(id, from, to)
+	if aerr != nil {
+		log.Println("Error while getting account statement:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	data, err := json.Marshal(statement)
 	if err != nil {
-		log.Println("Error while parsing results:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Println("Error while parsing account statement:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
 		return
 	}
 
@@ -54,55 +142,57 @@ func GetAccounts(w http.ResponseWriter, r *http.Request, context *ledgerContext.
 	return
 }
 
-func unmarshalToAccount(r *http.Request, account *models.Account) error {
+func unmarshalToAccount(r *http.Request, account *models.Account, schemas *models.DataSchemas) ledgerError.ApplicationError {
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return err
+		return models.PayloadInvalidError(err)
 	}
 	err = json.Unmarshal(body, account)
 	if err != nil {
-		return err
+		return models.PayloadInvalidError(err)
 	}
-	var validKey = regexp.MustCompile(`^[a-z_A-Z]+$`)
-	for key := range account.Data {
-		if !validKey.MatchString(key) {
-			return fmt.Errorf("Invalid key in data json: %v", key)
-		}
+	aerr := models.ValidateData(account.Data)
+	if aerr != nil {
+		return aerr
 	}
-	return nil
+	return schemas.Validate(models.AuditEntityAccount, account.Data)
 }
 
 // AddAccount creates a new account with the input ID and data
 func AddAccount(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
 	account := &models.Account{}
-	err := unmarshalToAccount(r, account)
-	if err != nil {
-		log.Println("Error loading payload:", err)
-		w.WriteHeader(http.StatusBadRequest)
-		//TODO Should we return any error message?
+	aerr := unmarshalToAccount(r, account, context.Schemas)
+	if aerr != nil {
+		log.Println("Error loading payload:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	if aerr := checkAccounts(r, account.ID); aerr != nil {
+		log.Println("Account is not allowed:", account.ID)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 
-	accountsDB := models.NewAccountDB(context.DB)
+	accountsDB := models.NewAccountDB(context.DB).WithAudit(auditInfo(r))
 	// Check if an account with same ID already exists
-	isExists, err := accountsDB.IsExists(account.ID)
-	if err != nil {
-		log.Println("Error while checking for existing account:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	isExists, aerr := accountsDB.IsExists(account.ID)
+	if aerr != nil {
+		log.Println("Error while checking for existing account:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 	if isExists {
 		log.Println("Account is conflicting:", account.ID)
-		w.WriteHeader(http.StatusConflict)
+		ledgerError.WriteResponse(w, models.AccountConflictError(account.ID))
 		return
 	}
 
 	// Otherwise, add account
-	aerr := accountsDB.CreateAccount(account)
+	aerr = accountsDB.CreateAccount(account)
 	if aerr != nil {
 		log.Printf("Error while adding account: %v (%v)", account.ID, aerr)
-		w.WriteHeader(http.StatusInternalServerError)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -112,32 +202,70 @@ func AddAccount(w http.ResponseWriter, r *http.Request, context *ledgerContext.A
 // UpdateAccount updates data of an account with the input ID
 func UpdateAccount(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
 	account := &models.Account{}
-	err := unmarshalToAccount(r, account)
-	if err != nil {
-		log.Println("Error loading payload:", err)
-		w.WriteHeader(http.StatusBadRequest)
+	aerr := unmarshalToAccount(r, account, context.Schemas)
+	if aerr != nil {
+		log.Println("Error loading payload:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	if aerr := checkAccounts(r, account.ID); aerr != nil {
+		log.Println("Account is not allowed:", account.ID)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	account.ExpectedVersion, aerr = expectedVersion(r, account.ExpectedVersion)
+	if aerr != nil {
+		log.Println("Invalid expected version:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 
-	accountsDB := models.NewAccountDB(context.DB)
+	accountsDB := models.NewAccountDB(context.DB).WithAudit(auditInfo(r))
 	// Check if an account with same ID already exists
-	isExists, err := accountsDB.IsExists(account.ID)
-	if err != nil {
-		log.Println("Error while checking for existing account:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	isExists, aerr := accountsDB.IsExists(account.ID)
+	if aerr != nil {
+		log.Println("Error while checking for existing account:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 	if !isExists {
 		log.Println("Account doesn't exist:", account.ID)
-		w.WriteHeader(http.StatusNotFound)
+		ledgerError.WriteResponse(w, models.AccountNotFoundError(account.ID))
 		return
 	}
 
 	// Otherwise, update account
-	aerr := accountsDB.UpdateAccount(account)
+	aerr = accountsDB.UpdateAccount(account)
 	if aerr != nil {
 		log.Printf("Error while updating account: %v (%v)", account.ID, aerr)
-		w.WriteHeader(http.StatusInternalServerError)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	return
+}
+
+// PatchAccount applies the JSON Merge Patch or JSON Patch in the request data
+// on the data of the account with the ID in the route
+func PatchAccount(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+	if aerr := checkAccounts(r, id); aerr != nil {
+		log.Println("Account is not allowed:", id)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	patch, aerr := unmarshalToPatch(r, id)
+	if aerr != nil {
+		log.Println("Error loading patch:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	accountsDB := models.NewAccountDB(context.DB).WithAudit(auditInfo(r)).WithSchemas(context.Schemas)
+	aerr = accountsDB.PatchAccount(patch)
+	if aerr != nil {
+		log.Printf("Error while patching account: %v (%v)", id, aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -14,6 +14,7 @@ import (
 	"github.com/RealImage/QLedger/middlewares"
 	"github.com/RealImage/QLedger/models"
 
+	"github.com/julienschmidt/httprouter"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -105,6 +106,74 @@ func (as *AccountsSearchSuite) TestAccountsSearch() {
 	assert.Equal(t, "acc1", accounts[0].ID, "Account ID doesn't match")
 }
 
+func (as *AccountsSearchSuite) TestGetAccount() {
+	t := as.T()
+
+	handler := middlewares.ParamsMiddleware(middlewares.ContextMiddleware(GetAccount, as.context))
+	req, err := http.NewRequest("GET", AccountSearchAPI+"/acc1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req, httprouter.Params{{Key: "id", Value: "acc1"}})
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+
+	var account models.AccountResult
+	err = json.Unmarshal(rr.Body.Bytes(), &account)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, "acc1", account.ID, "Account ID doesn't match")
+
+	// Invalid as_of timestamp
+	req, err = http.NewRequest("GET", AccountSearchAPI+"/acc1?as_of=yesterday", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler(rr, req, httprouter.Params{{Key: "id", Value: "acc1"}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Invalid response code")
+
+	// Non-existing account
+	req, err = http.NewRequest("GET", AccountSearchAPI+"/acc0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler(rr, req, httprouter.Params{{Key: "id", Value: "acc0"}})
+	assert.Equal(t, http.StatusNotFound, rr.Code, "Invalid response code")
+}
+
+func (as *AccountsSearchSuite) TestGetAccountStatement() {
+	t := as.T()
+
+	handler := middlewares.ParamsMiddleware(middlewares.ContextMiddleware(GetAccountStatement, as.context))
+	params := httprouter.Params{{Key: "id", Value: "acc1"}}
+	req, err := http.NewRequest("GET", AccountSearchAPI+"/acc1/statement?from=2017-01-01%2000:00:00.000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req, params)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+
+	var statement models.Statement
+	err = json.Unmarshal(rr.Body.Bytes(), &statement)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, "acc1", statement.AccountID, "Account ID doesn't match")
+
+	// Invalid period
+	req, err = http.NewRequest("GET", AccountSearchAPI+"/acc1/statement?to=yesterday", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler(rr, req, params)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Invalid response code")
+}
+
 func TestAccountsSuite(t *testing.T) {
 	suite.Run(t, new(AccountsSearchSuite))
 }
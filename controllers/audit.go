@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	ledgerContext "github.com/RealImage/QLedger/context"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/middlewares"
+	"github.com/RealImage/QLedger/models"
+)
+
+// auditInfo returns the caller, ID and endpoint of the request to record its changes in the audit log
+func auditInfo(r *http.Request) *models.AuditInfo {
+	audit := &models.AuditInfo{
+		RequestID: middlewares.RequestID(r),
+		Endpoint:  r.Method + " " + r.URL.Path,
+	}
+	if identity := middlewares.RequestIdentity(r); identity != nil {
+		audit.Identity = identity.Name
+	}
+	return audit
+}
+
+// GetAuditLog returns the list of audit log entries that matches the search query
+func GetAuditLog(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	if accountFilter(r).IsRestricted() {
+		ledgerError.WriteResponse(w, models.AccountsRestrictedError())
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Error reading payload:", err)
+		ledgerError.WriteResponse(w, models.PayloadInvalidError(err))
+		return
+	}
+	defer r.Body.Close()
+	query := string(body)
+
+	engine, aerr := models.NewSearchEngine(context.DB, models.SearchNamespaceAudit)
+	if aerr != nil {
+		log.Println("Error while creating Search Engine:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	results, aerr := engine.Query(query)
+	if aerr != nil {
+		log.Println("Error while querying:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		log.Println("Error while parsing results:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+	return
+}
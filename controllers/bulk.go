@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+
+	ledgerContext "github.com/RealImage/QLedger/context"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
+)
+
+// BulkImport imports the NDJSON records of accounts and transactions in the request data
+// and streams back the result of each record as NDJSON
+func BulkImport(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	defer r.Body.Close()
+	if accountFilter(r).IsRestricted() {
+		ledgerError.WriteResponse(w, models.AccountsRestrictedError())
+		return
+	}
+	maxBytes := context.BulkMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = models.BulkMaxBytes
+	}
+	// The request data is read completely before streaming the results,
+	// since the request body can't be read once the response is started in HTTP/1.x
+	file, err := ioutil.TempFile("", "qledger-bulk-")
+	if err != nil {
+		log.Println("Error creating temporary file:", err)
+		ledgerError.WriteResponse(w, models.InternalError(err))
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err := io.Copy(file, http.MaxBytesReader(w, r.Body, maxBytes)); err != nil {
+		log.Println("Error reading payload:", err)
+		ledgerError.WriteResponse(w, models.PayloadInvalidError(err))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Println("Error reading temporary file:", err)
+		ledgerError.WriteResponse(w, models.InternalError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	importer := models.NewBulkImporter(context.DB, models.BulkBatchSize).WithAudit(auditInfo(r)).WithSchemas(context.Schemas)
+	err = importer.Import(file, func(results []*models.BulkResult) error {
+		for _, result := range results {
+			if err := encoder.Encode(result); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error while importing:", err)
+	}
+	return
+}
+
+// Export streams all the accounts and transactions in the ledger as NDJSON records,
+// followed by a trailer record with the checksums of the export
+func Export(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	if accountFilter(r).IsRestricted() {
+		ledgerError.WriteResponse(w, models.AccountsRestrictedError())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	exporter := models.NewExporter(context.DB)
+	err := exporter.Export(w)
+	if err != nil {
+		// The response can't be changed once the export is started,
+		// so the missing trailer indicates the failure to the clients
+		log.Println("Error while exporting:", err)
+	}
+	return
+}
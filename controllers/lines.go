@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	ledgerContext "github.com/RealImage/QLedger/context"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/middlewares"
+	"github.com/RealImage/QLedger/models"
+)
+
+// GetLines returns the list of transaction lines that matches the search query.
+// The lines have the data of both their transactions and accounts, so the caller also needs the scope to read accounts.
+func GetLines(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	if identity := middlewares.RequestIdentity(r); identity != nil && !identity.HasScope(models.ScopeAccountsRead) {
+		log.Printf("Identity is not allowed the scope: %v (%v)", identity.Name, models.ScopeAccountsRead)
+		ledgerError.WriteResponse(w, middlewares.ForbiddenError(models.ScopeAccountsRead))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Error reading payload:", err)
+		ledgerError.WriteResponse(w, models.PayloadInvalidError(err))
+		return
+	}
+	defer r.Body.Close()
+
+	engine, aerr := models.NewSearchEngine(context.DB, models.SearchNamespaceLines)
+	if aerr != nil {
+		log.Println("Error while creating Search Engine:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	engine.RestrictAccounts(accountFilter(r))
+	query := string(body)
+
+	results, aerr := engine.Query(query)
+	if aerr != nil {
+		log.Println("Error while querying:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		log.Println("Error while parsing results:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+	return
+}
@@ -2,51 +2,53 @@ package controllers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"regexp"
-	"time"
 
 	ledgerContext "github.com/RealImage/QLedger/context"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/middlewares"
 	"github.com/RealImage/QLedger/models"
 )
 
-func unmarshalToTransaction(r *http.Request, txn *models.Transaction) error {
+func unmarshalToTransaction(r *http.Request, txn *models.Transaction, schemas *models.DataSchemas) ledgerError.ApplicationError {
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return err
+		return models.PayloadInvalidError(err)
 	}
-	err = json.Unmarshal(body, txn)
+	return parseTransaction(body, txn, schemas)
+}
+
+func parseTransaction(body []byte, txn *models.Transaction, schemas *models.DataSchemas) ledgerError.ApplicationError {
+	err := json.Unmarshal(body, txn)
 	if err != nil {
-		return err
+		return models.PayloadInvalidError(err)
 	}
-	var validKey = regexp.MustCompile(`^[a-z_A-Z]+$`)
-	for key := range txn.Data {
-		if !validKey.MatchString(key) {
-			return fmt.Errorf("Invalid key in data json: %v", key)
-		}
+	aerr := models.ValidateData(txn.Data)
+	if aerr != nil {
+		return aerr
 	}
-	// Validate timestamp format if present
-	if txn.Timestamp != "" {
-		_, err := time.Parse(models.LedgerTimestampLayout, txn.Timestamp)
-		if err != nil {
-			return err
-		}
+	aerr = schemas.Validate(models.AuditEntityTransaction, txn.Data)
+	if aerr != nil {
+		return aerr
 	}
-
-	return nil
+	// Validate timestamp format if present
+	return models.ValidateTimestamp(txn.Timestamp)
 }
 
 // MakeTransaction creates a new transaction from the request data
 func MakeTransaction(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
 	transaction := &models.Transaction{}
-	err := unmarshalToTransaction(r, transaction)
-	if err != nil {
-		log.Println("Error loading payload:", err)
-		w.WriteHeader(http.StatusBadRequest)
+	aerr := unmarshalToTransaction(r, transaction, context.Schemas)
+	if aerr == nil {
+		aerr = models.ValidateReservedKeys(transaction.Data)
+	}
+	if aerr != nil {
+		log.Println("Error loading payload:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 
@@ -54,31 +56,36 @@ func MakeTransaction(w http.ResponseWriter, r *http.Request, context *ledgerCont
 	// by validating the delta values
 	if !transaction.IsValid() {
 		log.Println("Transaction is invalid:", transaction.ID)
-		w.WriteHeader(http.StatusBadRequest)
+		ledgerError.WriteResponse(w, models.TransactionInvalidError(transaction.ID))
+		return
+	}
+	if aerr := checkAccounts(r, lineAccounts(transaction.Lines)...); aerr != nil {
+		log.Println("Transaction has accounts not allowed:", transaction.ID)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 
-	transactionsDB := models.NewTransactionDB(context.DB)
+	transactionsDB := models.NewTransactionDB(context.DB).WithAudit(auditInfo(r))
 	// Check if a transaction with same ID already exists
-	isExists, err := transactionsDB.IsExists(transaction.ID)
-	if err != nil {
-		log.Println("Error while checking for existing transaction:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	isExists, aerr := transactionsDB.IsExists(transaction.ID)
+	if aerr != nil {
+		log.Println("Error while checking for existing transaction:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 	if isExists {
 		// Check if the transaction lines are different
 		// and conflicts with the existing lines
-		isConflict, err := transactionsDB.IsConflict(transaction)
-		if err != nil {
-			log.Println("Error while checking for conflicting transaction:", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		isConflict, aerr := transactionsDB.IsConflict(transaction)
+		if aerr != nil {
+			log.Println("Error while checking for conflicting transaction:", aerr)
+			ledgerError.WriteResponse(w, aerr)
 			return
 		}
 		if isConflict {
 			// The conflicting transactions are denied
 			log.Println("Transaction is conflicting:", transaction.ID)
-			w.WriteHeader(http.StatusConflict)
+			ledgerError.WriteResponse(w, models.TransactionConflictError(transaction.ID))
 			return
 		}
 		// Otherwise the transaction is just a duplicate
@@ -92,7 +99,161 @@ func MakeTransaction(w http.ResponseWriter, r *http.Request, context *ledgerCont
 	done := transactionsDB.Transact(transaction)
 	if !done {
 		log.Println("Transaction failed:", transaction.ID)
-		w.WriteHeader(http.StatusInternalServerError)
+		ledgerError.WriteResponse(w, models.TransactionFailedError(transaction.ID))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	return
+}
+
+// MakeTransactionBatch creates all the transactions in the request data atomically
+func MakeTransactionBatch(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Error reading payload:", err)
+		ledgerError.WriteResponse(w, models.PayloadInvalidError(err))
+		return
+	}
+	var items []json.RawMessage
+	err = json.Unmarshal(body, &items)
+	if err == nil && len(items) == 0 {
+		err = errors.New("Empty transaction batch")
+	}
+	if err != nil {
+		log.Println("Error loading payload:", err)
+		ledgerError.WriteResponse(w, models.PayloadInvalidError(err))
+		return
+	}
+
+	// Skip the entire batch if any of the transactions is invalid
+	transactions := make([]*models.Transaction, 0, len(items))
+	results := make([]*models.BatchItemResult, 0, len(items))
+	isInvalid := false
+	for _, item := range items {
+		transaction := &models.Transaction{}
+		aerr := parseTransaction(item, transaction, context.Schemas)
+		if aerr == nil {
+			aerr = models.ValidateReservedKeys(transaction.Data)
+		}
+		if aerr != nil || !transaction.IsValid() {
+			log.Println("Transaction is invalid:", transaction.ID, aerr)
+			result := &models.BatchItemResult{ID: transaction.ID, Status: models.BatchStatusInvalid}
+			if aerr != nil {
+				result.Error = ledgerError.NewResponse(aerr)
+			}
+			results = append(results, result)
+			isInvalid = true
+			continue
+		}
+		// Deny the entire batch if any of the transactions has accounts not allowed
+		if aerr := checkAccounts(r, lineAccounts(transaction.Lines)...); aerr != nil {
+			log.Println("Transaction has accounts not allowed:", transaction.ID)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+		transactions = append(transactions, transaction)
+		results = append(results, &models.BatchItemResult{ID: transaction.ID, Status: models.BatchStatusAborted})
+	}
+
+	if isInvalid {
+		ledgerError.WriteResponse(w, models.BatchInvalidError(results))
+		return
+	}
+
+	transactionsDB := models.NewTransactionDB(context.DB).WithAudit(auditInfo(r))
+	results, aerr := transactionsDB.TransactBatch(transactions)
+	if aerr != nil {
+		log.Println("Transaction batch failed:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	status := batchStatus(results)
+	if status == http.StatusConflict {
+		ledgerError.WriteResponse(w, models.BatchConflictError(results))
+		return
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		log.Println("Error while parsing results:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(data)
+	return
+}
+
+// batchStatus returns the response code of a batch from the results of its transactions
+func batchStatus(results []*models.BatchItemResult) int {
+	status := http.StatusAccepted
+	for _, result := range results {
+		switch result.Status {
+		case models.BatchStatusConflict:
+			return http.StatusConflict
+		case models.BatchStatusCreated:
+			status = http.StatusCreated
+		}
+	}
+	return status
+}
+
+// ReverseTransaction creates a reversal transaction for the transaction with the ID in the route
+func ReverseTransaction(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+	reversal := &models.Transaction{}
+	// The data is validated against the schemas once it is linked to the transaction being reversed
+	aerr := unmarshalToTransaction(r, reversal, nil)
+	if aerr == nil {
+		aerr = models.ValidateReservedKeys(reversal.Data)
+	}
+	if aerr != nil {
+		log.Println("Error loading payload:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	// The reversal lines are always derived from the transaction being reversed
+	if reversal.ID == "" || len(reversal.Lines) != 0 {
+		log.Println("Reversal is invalid:", reversal.ID)
+		ledgerError.WriteResponse(w, models.ReversalInvalidError(reversal.ID))
+		return
+	}
+
+	transactionsDB := models.NewTransactionDB(context.DB).WithAudit(auditInfo(r)).WithSchemas(context.Schemas)
+	// Check if the accounts of the transaction being reversed are allowed
+	if accountFilter(r).IsRestricted() {
+		transaction, aerr := transactionsDB.GetResultByID(id)
+		if aerr != nil {
+			log.Println("Error while getting transaction:", aerr)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+		if aerr := checkAccounts(r, lineResultAccounts(transaction.Lines)...); aerr != nil {
+			log.Println("Transaction has accounts not allowed:", id)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+	}
+	// Check if the reversal is being repeated
+	isExists, aerr := transactionsDB.IsExists(reversal.ID)
+	if aerr != nil {
+		log.Println("Error while checking for existing transaction:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	aerr = transactionsDB.Reverse(id, reversal)
+	if aerr != nil {
+		log.Printf("Error while reversing transaction: %v (%v)", id, aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	if isExists {
+		// The repeated reversals are ignored
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -104,35 +265,82 @@ func GetTransactions(w http.ResponseWriter, r *http.Request, context *ledgerCont
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Println("Error reading payload:", err)
-		w.WriteHeader(http.StatusBadRequest)
+		ledgerError.WriteResponse(w, models.PayloadInvalidError(err))
 		return
 	}
 	defer r.Body.Close()
 
 	engine, aerr := models.NewSearchEngine(context.DB, models.SearchNamespaceTransactions)
 	if aerr != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Println("Error while creating Search Engine:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
+	engine.RestrictAccounts(accountFilter(r))
 	query := string(body)
 
 	results, aerr := engine.Query(query)
 	if aerr != nil {
 		log.Println("Error while querying:", aerr)
-		switch aerr.ErrorCode() {
-		case "search.query.invalid":
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+		ledgerError.WriteResponse(w, aerr)
+		return
 	}
 
 	data, err := json.Marshal(results)
 	if err != nil {
 		log.Println("Error while parsing results:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		ledgerError.WriteResponse(w, models.JSONError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+	return
+}
+
+// GetTransaction returns the transaction with the ID in the route,
+// with the data of the optional `as_of_version` version
+func GetTransaction(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+	asOfVersion, aerr := parseVersion(r.URL.Query().Get("as_of_version"))
+	if aerr != nil {
+		log.Println("Invalid as_of_version:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	transactionsDB := models.NewTransactionDB(context.DB)
+	transaction, aerr := transactionsDB.GetResultByID(id)
+	if aerr != nil {
+		log.Println("Error while getting transaction:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	// The transactions without any of the allowed accounts are hidden,
+	// and only the lines of the allowed accounts are shown
+	accounts := accountFilter(r)
+	if !accounts.AllowsAny(transaction.Lines) {
+		log.Println("Transaction has no accounts allowed:", id)
+		ledgerError.WriteResponse(w, models.TransactionNotFoundError(id))
+		return
+	}
+	transaction.Lines = accounts.FilterLines(transaction.Lines)
+	if asOfVersion != 0 {
+		version, aerr := transactionsDB.GetVersion(id, asOfVersion)
+		if aerr != nil {
+			log.Println("Error while getting transaction version:", aerr)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+		transaction.Data = version.Data
+		transaction.Version = version.Version
+	}
+	setETag(w, transaction.Version)
+
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		log.Println("Error while parsing transaction:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
 		return
 	}
 
@@ -144,32 +352,90 @@ func GetTransactions(w http.ResponseWriter, r *http.Request, context *ledgerCont
 // UpdateTransaction updates the data of a transaction with the input ID
 func UpdateTransaction(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
 	transaction := &models.Transaction{}
-	err := unmarshalToTransaction(r, transaction)
-	if err != nil {
-		log.Println("Error loading payload:", err)
-		w.WriteHeader(http.StatusBadRequest)
+	aerr := unmarshalToTransaction(r, transaction, context.Schemas)
+	if aerr != nil {
+		log.Println("Error loading payload:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	transaction.ExpectedVersion, aerr = expectedVersion(r, transaction.ExpectedVersion)
+	if aerr != nil {
+		log.Println("Invalid expected version:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 
-	transactionDB := models.NewTransactionDB(context.DB)
+	transactionDB := models.NewTransactionDB(context.DB).WithAudit(auditInfo(r)).WithFrozenKeys(context.FrozenKeys)
+	// Check if the accounts of the existing transaction are allowed
+	if accountFilter(r).IsRestricted() {
+		existing, aerr := transactionDB.GetResultByID(transaction.ID)
+		if aerr != nil {
+			log.Println("Error while getting transaction:", aerr)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+		if aerr := checkAccounts(r, lineResultAccounts(existing.Lines)...); aerr != nil {
+			log.Println("Transaction has accounts not allowed:", transaction.ID)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+	}
 	// Check if a transaction with same ID already exists
-	isExists, err := transactionDB.IsExists(transaction.ID)
-	if err != nil {
-		log.Println("Error while checking for existing transaction:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	isExists, aerr := transactionDB.IsExists(transaction.ID)
+	if aerr != nil {
+		log.Println("Error while checking for existing transaction:", aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 	if !isExists {
 		log.Println("Transaction doesn't exist:", transaction.ID)
-		w.WriteHeader(http.StatusNotFound)
+		ledgerError.WriteResponse(w, models.TransactionNotFoundError(transaction.ID))
 		return
 	}
 
 	// Otherwise, update transaction
-	terr := transactionDB.UpdateTransaction(transaction)
-	if terr != nil {
-		log.Printf("Error while updating transaction: %v (%v)", transaction.ID, terr)
-		w.WriteHeader(http.StatusInternalServerError)
+	aerr = transactionDB.UpdateTransaction(transaction)
+	if aerr != nil {
+		log.Printf("Error while updating transaction: %v (%v)", transaction.ID, aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	return
+}
+
+// PatchTransaction applies the JSON Merge Patch or JSON Patch in the request data
+// on the data of the transaction with the ID in the route
+func PatchTransaction(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+	patch, aerr := unmarshalToPatch(r, id)
+	if aerr != nil {
+		log.Println("Error loading patch:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	transactionDB := models.NewTransactionDB(context.DB).WithAudit(auditInfo(r)).
+		WithFrozenKeys(context.FrozenKeys).WithSchemas(context.Schemas)
+	// Check if the accounts of the existing transaction are allowed
+	if accountFilter(r).IsRestricted() {
+		existing, aerr := transactionDB.GetResultByID(id)
+		if aerr != nil {
+			log.Println("Error while getting transaction:", aerr)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+		if aerr := checkAccounts(r, lineResultAccounts(existing.Lines)...); aerr != nil {
+			log.Println("Transaction has accounts not allowed:", id)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+	}
+
+	aerr = transactionDB.PatchTransaction(patch)
+	if aerr != nil {
+		log.Printf("Error while patching transaction: %v (%v)", id, aerr)
+		ledgerError.WriteResponse(w, aerr)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -14,6 +14,7 @@ import (
 	"github.com/RealImage/QLedger/middlewares"
 	"github.com/RealImage/QLedger/models"
 
+	"github.com/julienschmidt/httprouter"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -147,6 +148,78 @@ func (as *TransactionSearchSuite) TestTransactionsSearch() {
 	assert.Equal(t, "txn1", transactions[0].ID, "Transaction ID doesn't match")
 }
 
+func (as *TransactionSearchSuite) TestGetTransaction() {
+	t := as.T()
+
+	handler := middlewares.ParamsMiddleware(middlewares.ContextMiddleware(GetTransaction, as.context))
+	req, err := http.NewRequest("GET", TransactionsSearchAPI+"/txn1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req, httprouter.Params{{Key: "id", Value: "txn1"}})
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+
+	var transaction models.TransactionResult
+	err = json.Unmarshal(rr.Body.Bytes(), &transaction)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, "txn1", transaction.ID, "Transaction ID doesn't match")
+	assert.Equal(t, 2, len(transaction.Lines), "Transaction lines count doesn't match")
+
+	// Non-existing transaction
+	req, err = http.NewRequest("GET", TransactionsSearchAPI+"/txn0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler(rr, req, httprouter.Params{{Key: "id", Value: "txn0"}})
+	assert.Equal(t, http.StatusNotFound, rr.Code, "Invalid response code")
+}
+
+func (as *TransactionSearchSuite) TestLinesSearch() {
+	t := as.T()
+	payload := `{
+        "query": {
+            "must": {
+                "fields": [
+                    {"delta": {"lt": 0}}
+                ],
+                "terms": [
+                    {"transaction.expiry": "2018-01-30"}
+                ]
+            }
+        }
+    }`
+	handler := middlewares.ContextMiddleware(GetLines, as.context)
+	req, err := http.NewRequest("GET", "/v1/lines", bytes.NewBufferString(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	var lines []models.LineResult
+	err = json.Unmarshal(rr.Body.Bytes(), &lines)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, 1, len(lines), "Lines count doesn't match")
+	assert.Equal(t, "txn3", lines[0].TransactionID, "Transaction ID doesn't match")
+	assert.Equal(t, "acc2", lines[0].AccountID, "Account ID doesn't match")
+	assert.Equal(t, -400, lines[0].Delta, "Delta doesn't match")
+
+	// The keys in the data should have the entity
+	req, err = http.NewRequest("GET", "/v1/lines", bytes.NewBufferString(`{"query": {"must": {"terms": [{"expiry": "2018-01-30"}]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Invalid response code")
+}
+
 func TestTransactionSearchSuite(t *testing.T) {
 	suite.Run(t, new(TransactionSearchSuite))
 }
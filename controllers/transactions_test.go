@@ -3,6 +3,7 @@ package controllers
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -10,8 +11,11 @@ import (
 	"testing"
 
 	ledgerContext "github.com/RealImage/QLedger/context"
+	ledgerError "github.com/RealImage/QLedger/errors"
 	"github.com/RealImage/QLedger/middlewares"
+	"github.com/RealImage/QLedger/models"
 
+	"github.com/julienschmidt/httprouter"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -143,6 +147,7 @@ func (ts *TransactionsSuite) TestInvalidTransaction() {
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code, "Invalid response code")
+	assert.Contains(t, rr.Body.String(), `"code":"transaction.invalid"`, "Invalid error code")
 }
 
 func (ts *TransactionsSuite) TestBadTransaction() {
@@ -251,6 +256,260 @@ func (ts *TransactionsSuite) TestCreateTransactionWithBoundaryValues() {
 	assert.Equal(t, http.StatusBadRequest, rr1.Code, "Invalid response code")
 }
 
+func (ts *TransactionsSuite) TestReverseTransaction() {
+	t := ts.T()
+
+	payload := `{
+	  "id": "t007",
+	  "lines": [
+	    {
+	      "account": "alice",
+	      "delta": 100
+	    },
+	    {
+	      "account": "bob",
+	      "delta": -100
+	    }
+	  ]
+	}`
+	handler := middlewares.ContextMiddleware(MakeTransaction, ts.context)
+	req, err := http.NewRequest("POST", TransactionsAPI, bytes.NewBufferString(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code, "Invalid response code")
+
+	reverse := func(id string, payload string) int {
+		handler := middlewares.ParamsMiddleware(middlewares.ContextMiddleware(ReverseTransaction, ts.context))
+		req, err := http.NewRequest("POST", TransactionsAPI+"/"+id+"/reverse", bytes.NewBufferString(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req, httprouter.Params{{Key: "id", Value: id}})
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusCreated, reverse("t007", `{"id": "t007_reversal"}`), "Invalid response code")
+	assert.Equal(t, http.StatusAccepted, reverse("t007", `{"id": "t007_reversal"}`), "Invalid response code")
+	assert.Equal(t, http.StatusConflict, reverse("t007", `{"id": "t007_reversal_two"}`), "Invalid response code")
+	assert.Equal(t, http.StatusNotFound, reverse("t000", `{"id": "t000_reversal"}`), "Invalid response code")
+	assert.Equal(t, http.StatusBadRequest, reverse("t007", `{}`), "Invalid response code")
+	// The keys linking the reversals can't be set by the clients
+	assert.Equal(t, http.StatusBadRequest, reverse("t007", `{"id": "t007_reversal_three", "data": {"reverses": "t000"}}`), "Invalid response code")
+}
+
+func (ts *TransactionsSuite) TestTransactionBatch() {
+	t := ts.T()
+
+	batch := func(payload string) *httptest.ResponseRecorder {
+		handler := middlewares.ContextMiddleware(MakeTransactionBatch, ts.context)
+		req, err := http.NewRequest("POST", TransactionsAPI+"/_batch", bytes.NewBufferString(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	payload := `[
+	  {
+	    "id": "t008",
+	    "lines": [
+	      {"account": "alice", "delta": 100},
+	      {"account": "bob", "delta": -100}
+	    ]
+	  },
+	  {
+	    "id": "t009",
+	    "lines": [
+	      {"account": "bob", "delta": 100},
+	      {"account": "carly", "delta": -100}
+	    ]
+	  }
+	]`
+	rr := batch(payload)
+	assert.Equal(t, http.StatusCreated, rr.Code, "Invalid response code")
+	var results []models.BatchItemResult
+	err := json.Unmarshal(rr.Body.Bytes(), &results)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, 2, len(results), "Results count doesn't match")
+
+	// Repeated batch
+	rr = batch(payload)
+	assert.Equal(t, http.StatusAccepted, rr.Code, "Invalid response code")
+
+	// Batch with invalid transaction
+	payload = `[
+	  {
+	    "id": "t010",
+	    "lines": [
+	      {"account": "alice", "delta": 100},
+	      {"account": "bob", "delta": -100}
+	    ]
+	  },
+	  {
+	    "id": "t011",
+	    "lines": [
+	      {"account": "alice", "delta": 100},
+	      {"account": "bob", "delta": -101}
+	    ]
+	  }
+	]`
+	rr = batch(payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Invalid response code")
+	var errorResponse struct {
+		Code    string                   `json:"code"`
+		Details []models.BatchItemResult `json:"details"`
+	}
+	err = json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, "batch.invalid", errorResponse.Code, "Invalid error code")
+	assert.Equal(t, models.BatchStatusAborted, errorResponse.Details[0].Status, "Transaction should be aborted")
+	assert.Equal(t, models.BatchStatusInvalid, errorResponse.Details[1].Status, "Transaction should be invalid")
+
+	// Batch with conflicting transaction
+	payload = `[
+	  {
+	    "id": "t008",
+	    "lines": [
+	      {"account": "alice", "delta": 200},
+	      {"account": "bob", "delta": -200}
+	    ]
+	  }
+	]`
+	rr = batch(payload)
+	assert.Equal(t, http.StatusConflict, rr.Code, "Invalid response code")
+	err = json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, "batch.conflict", errorResponse.Code, "Invalid error code")
+	assert.Equal(t, models.BatchStatusConflict, errorResponse.Details[0].Status, "Transaction should be conflicting")
+}
+
+type tokenStore map[string]*models.APIToken
+
+func (s tokenStore) GetByToken(token string) (*models.APIToken, ledgerError.ApplicationError) {
+	return s[token], nil
+}
+
+func (s tokenStore) HasTokens() (bool, ledgerError.ApplicationError) {
+	return len(s) != 0, nil
+}
+
+func (ts *TransactionsSuite) TestRestrictedTransaction() {
+	t := ts.T()
+
+	auth := middlewares.NewTokenAuth(tokenStore{
+		"PARTNER42": &models.APIToken{
+			Name:     "partner42",
+			Scopes:   []string{models.ScopeTransactionsWrite, models.ScopeTransactionsRead},
+			Accounts: models.AccountPatterns{"PARTNER42.*"},
+		},
+	})
+	request := func(handler http.HandlerFunc, method string, payload string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, TransactionsAPI, bytes.NewBufferString(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", "PARTNER42")
+		rr := httptest.NewRecorder()
+		middlewares.AuthMiddleware(auth, "", handler).ServeHTTP(rr, req)
+		return rr
+	}
+	makeTransaction := middlewares.ContextMiddleware(MakeTransaction, ts.context)
+	getTransactions := middlewares.ContextMiddleware(GetTransactions, ts.context)
+
+	// Transaction with allowed accounts
+	rr := request(makeTransaction, "POST", `{
+	  "id": "t020",
+	  "lines": [
+	    {"account": "PARTNER42.cash", "delta": 100},
+	    {"account": "PARTNER42.fees", "delta": -100}
+	  ]
+	}`)
+	assert.Equal(t, http.StatusCreated, rr.Code, "Invalid response code")
+
+	// Transaction with an account not allowed
+	rr = request(makeTransaction, "POST", `{
+	  "id": "t021",
+	  "lines": [
+	    {"account": "PARTNER42.cash", "delta": 100},
+	    {"account": "alice", "delta": -100}
+	  ]
+	}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "Invalid response code")
+	assert.Contains(t, rr.Body.String(), `"code":"account.forbidden"`, "Invalid error code")
+
+	// Search results have only the transactions of allowed accounts
+	rr = request(getTransactions, "GET", `{"query": {"must": {"terms": [{}]}}}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	var results []models.TransactionResult
+	err := json.Unmarshal(rr.Body.Bytes(), &results)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, 1, len(results), "Invalid number of transactions")
+	assert.Equal(t, "t020", results[0].ID, "Invalid transaction")
+
+	// Transaction with an account not allowed, which is made by an unrestricted caller
+	req, err := http.NewRequest("POST", TransactionsAPI, bytes.NewBufferString(`{
+	  "id": "t022",
+	  "lines": [
+	    {"account": "PARTNER42.cash", "delta": 100},
+	    {"account": "alice", "delta": -100}
+	  ]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	makeTransaction.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code, "Invalid response code")
+
+	// Search results have only the lines of allowed accounts
+	rr = request(getTransactions, "GET", `{"query": {"must": {"terms": [{}]}}}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	results = nil
+	err = json.Unmarshal(rr.Body.Bytes(), &results)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, 2, len(results), "Invalid number of transactions")
+	for _, result := range results {
+		for _, line := range result.Lines {
+			assert.NotEqual(t, "alice", line.AccountID, "Line of the account not allowed should be hidden")
+		}
+	}
+
+	// Transaction has only the lines of allowed accounts
+	getTransaction := middlewares.ParamsMiddleware(middlewares.ContextMiddleware(GetTransaction, ts.context))
+	req, err = http.NewRequest("GET", TransactionsAPI+"/t022", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Authorization", "PARTNER42")
+	rr = httptest.NewRecorder()
+	middlewares.AuthMiddleware(auth, "", func(w http.ResponseWriter, r *http.Request) {
+		getTransaction(w, r, httprouter.Params{{Key: "id", Value: "t022"}})
+	}).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	var transaction models.TransactionResult
+	err = json.Unmarshal(rr.Body.Bytes(), &transaction)
+	if err != nil {
+		t.Errorf("Invalid json response: %v", rr.Body.String())
+	}
+	assert.Equal(t, 1, len(transaction.Lines), "Invalid number of lines")
+	assert.Equal(t, "PARTNER42.cash", transaction.Lines[0].AccountID, "Invalid line account")
+}
+
 func (ts *TransactionsSuite) TearDownSuite() {
 	log.Println("Cleaning up the test database")
 
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	ledgerContext "github.com/RealImage/QLedger/context"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/middlewares"
+	"github.com/RealImage/QLedger/models"
+)
+
+// parseVersion parses a data version, which is a positive integer. The empty version is parsed as 0.
+func parseVersion(value string) (int64, ledgerError.ApplicationError) {
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, models.VersionInvalidError(value)
+	}
+	return version, nil
+}
+
+// expectedVersion returns the version expected by an update, from either the `If-Match` header
+// or the `expected_version` field of the payload. The `If-Match` header has the version as its ETag.
+func expectedVersion(r *http.Request, field int64) (int64, ledgerError.ApplicationError) {
+	if field < 0 {
+		return 0, models.VersionInvalidError(strconv.FormatInt(field, 10))
+	}
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return field, nil
+	}
+	version, aerr := parseVersion(strings.Trim(ifMatch, `"`))
+	if aerr != nil {
+		return 0, models.VersionInvalidError(ifMatch)
+	}
+	if field != 0 && field != version {
+		return 0, models.VersionInvalidError(ifMatch)
+	}
+	return version, nil
+}
+
+// unmarshalToPatch parses the patch in the request data, which expects the version in the `If-Match` header if any
+func unmarshalToPatch(r *http.Request, id string) (*models.DataPatch, ledgerError.ApplicationError) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, models.PayloadInvalidError(err)
+	}
+	patch, aerr := models.NewDataPatch(id, r.Header.Get("Content-Type"), body)
+	if aerr != nil {
+		return nil, aerr
+	}
+	patch.ExpectedVersion, aerr = expectedVersion(r, 0)
+	if aerr != nil {
+		return nil, aerr
+	}
+	return patch, nil
+}
+
+// setETag sets the version of the data as the ETag of the response
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// writeVersions writes the versions of the data as the JSON response
+func writeVersions(w http.ResponseWriter, versions []*models.DataVersion) {
+	data, err := json.Marshal(versions)
+	if err != nil {
+		log.Println("Error while parsing versions:", err)
+		ledgerError.WriteResponse(w, models.JSONError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+}
+
+// GetAccountVersions returns all the versions of the data of the account with the ID in the route
+func GetAccountVersions(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+	if aerr := checkAccounts(r, id); aerr != nil {
+		log.Println("Account is not allowed:", id)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+
+	accountsDB := models.NewAccountDB(context.DB)
+	versions, aerr := accountsDB.GetVersions(id)
+	if aerr != nil {
+		log.Println("Error while getting account versions:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	writeVersions(w, versions)
+	return
+}
+
+// GetTransactionVersions returns all the versions of the data of the transaction with the ID in the route
+func GetTransactionVersions(w http.ResponseWriter, r *http.Request, context *ledgerContext.AppContext) {
+	id := middlewares.Params(r).ByName("id")
+
+	transactionsDB := models.NewTransactionDB(context.DB)
+	// The transactions without any of the allowed accounts are hidden
+	if accountFilter(r).IsRestricted() {
+		transaction, aerr := transactionsDB.GetResultByID(id)
+		if aerr != nil {
+			log.Println("Error while getting transaction:", aerr)
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+		if !accountFilter(r).AllowsAny(transaction.Lines) {
+			log.Println("Transaction has no accounts allowed:", id)
+			ledgerError.WriteResponse(w, models.TransactionNotFoundError(id))
+			return
+		}
+	}
+	versions, aerr := transactionsDB.GetVersions(id)
+	if aerr != nil {
+		log.Println("Error while getting transaction versions:", aerr)
+		ledgerError.WriteResponse(w, aerr)
+		return
+	}
+	writeVersions(w, versions)
+	return
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectedVersion(t *testing.T) {
+	req, err := http.NewRequest("PUT", "/v1/transactions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, aerr := expectedVersion(req, 0)
+	assert.Nil(t, aerr, "Error while parsing expected version")
+	assert.Equal(t, int64(0), version, "Invalid expected version")
+
+	version, aerr = expectedVersion(req, 3)
+	assert.Nil(t, aerr, "Error while parsing expected version")
+	assert.Equal(t, int64(3), version, "Invalid expected version")
+
+	req.Header.Set("If-Match", `"4"`)
+	version, aerr = expectedVersion(req, 0)
+	assert.Nil(t, aerr, "Error while parsing expected version")
+	assert.Equal(t, int64(4), version, "Invalid expected version")
+
+	_, aerr = expectedVersion(req, 3)
+	assert.Equal(t, "version.invalid", aerr.ErrorCode(), "Conflicting versions should be invalid")
+
+	req.Header.Set("If-Match", `"abc"`)
+	_, aerr = expectedVersion(req, 0)
+	assert.Equal(t, "version.invalid", aerr.ErrorCode(), "Invalid error code")
+
+	req.Header.Set("If-Match", "*")
+	version, aerr = expectedVersion(req, 0)
+	assert.Nil(t, aerr, "Error while parsing expected version")
+	assert.Equal(t, int64(0), version, "Invalid expected version")
+
+	_, aerr = parseVersion("0")
+	assert.Equal(t, "version.invalid", aerr.ErrorCode(), "Invalid error code")
+}
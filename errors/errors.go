@@ -13,12 +13,14 @@ type ApplicationError interface {
 	String() string
 	ErrorCode() string
 	ErrorMessage() string
+	ErrorDetails() interface{}
 }
 
 // BaseApplicationError implements the `ApplicationError`
 type BaseApplicationError struct {
 	Message string
 	Code    string
+	Details interface{}
 }
 
 // ErrorCode returns the unique code of the error
@@ -31,6 +33,11 @@ func (e *BaseApplicationError) ErrorMessage() string {
 	return e.Message
 }
 
+// ErrorDetails returns the additional details of the error if any
+func (e *BaseApplicationError) ErrorDetails() interface{} {
+	return e.Details
+}
+
 // Error returns string representation of error
 func (e *BaseApplicationError) Error() string {
 	return fmt.Sprintf("%v (%v)", e.Message, e.Code)
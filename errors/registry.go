@@ -0,0 +1,83 @@
+package errors
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// statusCodes is the registry of the HTTP status codes of the application errors.
+// The errors that are not registered are considered as internal server errors.
+var statusCodes = map[string]int{
+	"payload.invalid":         http.StatusBadRequest,
+	"data.key.invalid":        http.StatusBadRequest,
+	"data.key.reserved":       http.StatusBadRequest,
+	"timestamp.invalid":       http.StatusBadRequest,
+	"search.query.invalid":    http.StatusBadRequest,
+	"search.field.invalid":    http.StatusBadRequest,
+	"search.operator.invalid": http.StatusBadRequest,
+	"search.value.invalid":    http.StatusBadRequest,
+	"auth.unauthorized":       http.StatusUnauthorized,
+	"auth.forbidden":          http.StatusForbidden,
+	"auth.nonce.limit":        http.StatusTooManyRequests,
+	"token.invalid":           http.StatusBadRequest,
+	"account.forbidden":       http.StatusForbidden,
+	"account.notfound":        http.StatusNotFound,
+	"account.conflict":        http.StatusConflict,
+	"transaction.invalid":     http.StatusBadRequest,
+	"transaction.notfound":    http.StatusNotFound,
+	"transaction.conflict":    http.StatusConflict,
+	"transaction.reversed":    http.StatusConflict,
+	"reversal.invalid":        http.StatusBadRequest,
+	"batch.invalid":           http.StatusBadRequest,
+	"batch.conflict":          http.StatusConflict,
+	"version.invalid":         http.StatusBadRequest,
+	"version.notfound":        http.StatusNotFound,
+	"version.mismatch":        http.StatusPreconditionFailed,
+	"patch.invalid":           http.StatusBadRequest,
+	"patch.type.invalid":      http.StatusUnsupportedMediaType,
+	"patch.conflict":          http.StatusConflict,
+	"data.key.frozen":         http.StatusConflict,
+	"data.schema.invalid":     http.StatusBadRequest,
+	"route.notfound":          http.StatusNotFound,
+	"route.method.invalid":    http.StatusMethodNotAllowed,
+}
+
+// StatusCode returns the HTTP status code of the error code
+func StatusCode(code string) int {
+	status, ok := statusCodes[code]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// Response is the JSON body of the error responses
+type Response struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details"`
+}
+
+// NewResponse returns the JSON body of the error
+func NewResponse(err ApplicationError) *Response {
+	return &Response{
+		Code:    err.ErrorCode(),
+		Message: err.ErrorMessage(),
+		Details: err.ErrorDetails(),
+	}
+}
+
+// WriteResponse writes the error as JSON response with the HTTP status code of the error code
+func WriteResponse(w http.ResponseWriter, err ApplicationError) {
+	data, merr := json.Marshal(NewResponse(err))
+	if merr != nil {
+		log.Println("Error while parsing error response:", merr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(StatusCode(err.ErrorCode()))
+	w.Write(data)
+}
@@ -0,0 +1,34 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusCode(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, StatusCode("data.key.invalid"), "Invalid status code")
+	assert.Equal(t, http.StatusNotFound, StatusCode("account.notfound"), "Invalid status code")
+	assert.Equal(t, http.StatusInternalServerError, StatusCode("db.error"), "Unregistered error should be internal")
+}
+
+func TestWriteResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WriteResponse(rr, &BaseApplicationError{
+		Code:    "data.key.invalid",
+		Message: "Invalid key in data json: a.b",
+		Details: map[string]interface{}{"key": "a.b"},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Invalid response code")
+	assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"), "Invalid content type")
+
+	var response Response
+	err := json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.Equal(t, nil, err, "Invalid json response")
+	assert.Equal(t, "data.key.invalid", response.Code, "Invalid error code")
+	assert.Equal(t, "Invalid key in data json: a.b", response.Message, "Invalid error message")
+	assert.Equal(t, map[string]interface{}{"key": "a.b"}, response.Details, "Invalid error details")
+}
@@ -5,10 +5,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 
 	ledgerContext "github.com/RealImage/QLedger/context"
 	"github.com/RealImage/QLedger/controllers"
 	"github.com/RealImage/QLedger/middlewares"
+	"github.com/RealImage/QLedger/models"
 	"github.com/julienschmidt/httprouter"
 	"github.com/mattes/migrate"
 	"github.com/mattes/migrate/database"
@@ -17,12 +19,6 @@ import (
 )
 
 func main() {
-	// Assert authentication
-	authToken, ok := os.LookupEnv("LEDGER_AUTH_TOKEN")
-	if !ok || authToken == "" {
-		log.Fatal("Cannot start the server. Authentication token is not set!! Please set LEDGER_AUTH_TOKEN")
-	}
-
 	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
 	if err != nil {
 		log.Panic("Unable to connect to Database:", err)
@@ -32,8 +28,35 @@ func main() {
 	// Migrate DB changes
 	migrateDB(db)
 
-	appContext := &ledgerContext.AppContext{DB: db}
+	if pattern := os.Getenv("LEDGER_DATA_KEY_PATTERN"); pattern != "" {
+		if err := models.SetDataKeyPattern(pattern); err != nil {
+			log.Fatal("Invalid LEDGER_DATA_KEY_PATTERN: ", err)
+		}
+	}
+
+	// Run the subcommand instead of the server if any
+	if len(os.Args) > 1 {
+		runCommand(db, os.Args[1], os.Args[2:])
+		return
+	}
+
+	// Authenticate the API requests using the scheme of the deployment
+	auth := newAuthenticator(db)
+	appContext := &ledgerContext.AppContext{
+		DB:           db,
+		FrozenKeys:   loadFrozenKeys(),
+		Schemas:      loadDataSchemas(),
+		BulkMaxBytes: loadBulkMaxBytes(),
+	}
+	// handler returns the authenticated handler of the controller, which requires the scope
+	handler := func(scope string, controller middlewares.Handler) http.HandlerFunc {
+		return middlewares.RequestIDMiddleware(
+			middlewares.AuthMiddleware(auth, scope,
+				middlewares.ContextMiddleware(controller, appContext)))
+	}
 	router := httprouter.New()
+	router.NotFound = http.HandlerFunc(middlewares.NotFound)
+	router.MethodNotAllowed = http.HandlerFunc(middlewares.MethodNotAllowed)
 
 	hostPrefix := os.Getenv("HOST_PREFIX")
 	// Monitors
@@ -41,40 +64,79 @@ func main() {
 
 	// Create accounts and transactions
 	router.HandlerFunc(http.MethodPost, hostPrefix+"/v1/accounts",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.AddAccount, appContext)))
+		handler(models.ScopeAccountsWrite, controllers.AddAccount))
 	router.HandlerFunc(http.MethodPost, hostPrefix+"/v1/transactions",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.MakeTransaction, appContext)))
+		handler(models.ScopeTransactionsWrite, controllers.MakeTransaction))
+	router.Handle(http.MethodPost, hostPrefix+"/v1/transactions/:id/reverse",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeTransactionsWrite, controllers.ReverseTransaction)))
 
 	// Read or search accounts and transactions
 	router.HandlerFunc(http.MethodGet, hostPrefix+"/v1/accounts",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.GetAccounts, appContext)))
+		handler(models.ScopeAccountsRead, controllers.GetAccounts))
 	router.HandlerFunc(http.MethodPost, hostPrefix+"/v1/accounts/_search",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.GetAccounts, appContext)))
+		handler(models.ScopeAccountsRead, controllers.GetAccounts))
+	router.Handle(http.MethodGet, hostPrefix+"/v1/accounts/:id",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeAccountsRead, controllers.GetAccount)))
+	router.Handle(http.MethodGet, hostPrefix+"/v1/accounts/:id/statement",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeAccountsRead, controllers.GetAccountStatement)))
+	router.Handle(http.MethodGet, hostPrefix+"/v1/accounts/:id/versions",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeAccountsRead, controllers.GetAccountVersions)))
 	router.HandlerFunc(http.MethodGet, hostPrefix+"/v1/transactions",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.GetTransactions, appContext)))
-	router.HandlerFunc(http.MethodPost, hostPrefix+"/v1/transactions/_search",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.GetTransactions, appContext)))
+		handler(models.ScopeTransactionsRead, controllers.GetTransactions))
+	// The search and batch routes share the wildcard route of transaction IDs
+	router.Handle(http.MethodPost, hostPrefix+"/v1/transactions/:id",
+		middlewares.ParamsMiddleware(
+			middlewares.ParamRoutes("id", map[string]http.HandlerFunc{
+				"_search": handler(models.ScopeTransactionsRead, controllers.GetTransactions),
+				"_batch":  handler(models.ScopeTransactionsWrite, controllers.MakeTransactionBatch),
+			})))
+	router.Handle(http.MethodGet, hostPrefix+"/v1/transactions/:id",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeTransactionsRead, controllers.GetTransaction)))
+	router.Handle(http.MethodGet, hostPrefix+"/v1/transactions/:id/versions",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeTransactionsRead, controllers.GetTransactionVersions)))
+
+	// Search the lines of transactions along with their accounts
+	router.HandlerFunc(http.MethodGet, hostPrefix+"/v1/lines",
+		handler(models.ScopeTransactionsRead, controllers.GetLines))
+	router.HandlerFunc(http.MethodPost, hostPrefix+"/v1/lines/_search",
+		handler(models.ScopeTransactionsRead, controllers.GetLines))
+
+	// Bulk import and export of accounts and transactions
+	router.HandlerFunc(http.MethodPost, hostPrefix+"/v1/_bulk",
+		handler(models.ScopeAdmin, controllers.BulkImport))
+	router.HandlerFunc(http.MethodGet, hostPrefix+"/v1/_export",
+		handler(models.ScopeAdmin, controllers.Export))
+
+	// Search the audit log of the changes to accounts and transactions
+	router.HandlerFunc(http.MethodGet, hostPrefix+"/v1/audit",
+		handler(models.ScopeAdmin, controllers.GetAuditLog))
+	router.HandlerFunc(http.MethodPost, hostPrefix+"/v1/audit/_search",
+		handler(models.ScopeAdmin, controllers.GetAuditLog))
 
 	// Update data of accounts and transactions
 	router.HandlerFunc(http.MethodPut, hostPrefix+"/v1/accounts",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.UpdateAccount, appContext)))
+		handler(models.ScopeAccountsWrite, controllers.UpdateAccount))
 	router.HandlerFunc(http.MethodPut, hostPrefix+"/v1/transactions",
-		middlewares.TokenAuthMiddleware(
-			middlewares.ContextMiddleware(controllers.UpdateTransaction, appContext)))
+		handler(models.ScopeTransactionsWrite, controllers.UpdateTransaction))
+	router.Handle(http.MethodPatch, hostPrefix+"/v1/accounts/:id",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeAccountsWrite, controllers.PatchAccount)))
+	router.Handle(http.MethodPatch, hostPrefix+"/v1/transactions/:id",
+		middlewares.ParamsMiddleware(
+			handler(models.ScopeTransactionsWrite, controllers.PatchTransaction)))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "7000"
 	}
 	log.Println("Running server on port:", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	log.Fatal(listenAndServe(":"+port, router))
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -83,6 +145,54 @@ func main() {
 	}()
 }
 
+// loadFrozenKeys returns the frozen keys of the transaction data in `LEDGER_FROZEN_KEYS_FILE`,
+// or the comma separated keys frozen in all the transactions in `LEDGER_FROZEN_KEYS`
+func loadFrozenKeys() *models.FrozenKeys {
+	if frozenKeysFile := os.Getenv("LEDGER_FROZEN_KEYS_FILE"); frozenKeysFile != "" {
+		frozenKeys, err := models.LoadFrozenKeysFile(frozenKeysFile)
+		if err != nil {
+			log.Fatal("Unable to load the frozen keys file:", err)
+		}
+		return frozenKeys
+	}
+	if value := os.Getenv("LEDGER_FROZEN_KEYS"); value != "" {
+		frozenKeys, err := models.ParseFrozenKeys(value)
+		if err != nil {
+			log.Fatal("Invalid LEDGER_FROZEN_KEYS: ", err)
+		}
+		return frozenKeys
+	}
+	return nil
+}
+
+// loadDataSchemas returns the JSON Schemas of the data of the accounts and transactions
+// in `LEDGER_SCHEMAS_FILE`, if set
+func loadDataSchemas() *models.DataSchemas {
+	schemasFile := os.Getenv("LEDGER_SCHEMAS_FILE")
+	if schemasFile == "" {
+		return nil
+	}
+	schemas, err := models.LoadDataSchemasFile(schemasFile)
+	if err != nil {
+		log.Fatal("Unable to load the schemas file:", err)
+	}
+	return schemas
+}
+
+// loadBulkMaxBytes returns the limit of the size of a bulk import request in `LEDGER_BULK_MAX_BYTES`,
+// or the default limit
+func loadBulkMaxBytes() int64 {
+	value := os.Getenv("LEDGER_BULK_MAX_BYTES")
+	if value == "" {
+		return models.BulkMaxBytes
+	}
+	maxBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxBytes <= 0 {
+		log.Fatal("Invalid LEDGER_BULK_MAX_BYTES: ", value)
+	}
+	return maxBytes
+}
+
 func migrateDB(db *sql.DB) {
 	log.Println("Starting db schema migration...")
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
@@ -1,25 +1,134 @@
 package middlewares
 
 import (
+	"context"
+	"crypto/subtle"
+	"log"
 	"net/http"
 	"os"
 	"strings"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
 )
 
+// Identity represents the authenticated caller of a request
+type Identity struct {
+	Name   string
+	Scopes []string
+	// Accounts restricts the identity to the matching accounts
+	Accounts models.AccountPatterns
+	// accounts is the filter of the account patterns, which is compiled once the identity is authenticated
+	accounts *models.AccountFilter
+}
+
+// HasScope says whether the identity is allowed the scope.
+// The `admin` scope allows all the scopes.
+func (i *Identity) HasScope(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope || s == models.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountFilter returns the filter of the accounts the identity is restricted to
+func (i *Identity) AccountFilter() *models.AccountFilter {
+	if i == nil {
+		return nil
+	}
+	if i.accounts == nil {
+		i.accounts = models.NewAccountFilter(i.Accounts)
+	}
+	return i.accounts
+}
+
+type identityKey struct{}
+
+// RequestIdentity returns the authenticated identity of the request, or nil if it is not authenticated
+func RequestIdentity(r *http.Request) *Identity {
+	identity, _ := r.Context().Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// Authenticator authenticates a request and returns the identity of its caller
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, ledgerError.ApplicationError)
+}
+
+// AuthMiddleware is a middleware that authenticates the request using the `Authenticator`
+// and allows only the identities having the scope
+func AuthMiddleware(auth Authenticator, scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, aerr := auth.Authenticate(r)
+		if aerr != nil {
+			ledgerError.WriteResponse(w, aerr)
+			return
+		}
+		if !identity.HasScope(scope) {
+			log.Printf("Identity is not allowed the scope: %v (%v)", identity.Name, scope)
+			ledgerError.WriteResponse(w, ForbiddenError(scope))
+			return
+		}
+		identity.accounts = models.NewAccountFilter(identity.Accounts)
+		ctx := context.WithValue(r.Context(), identityKey{}, identity)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// TokenAuth authenticates the requests using the `LEDGER_AUTH_TOKEN` and the named API tokens of the store
+type TokenAuth struct {
+	store models.TokenStore
+}
+
+// NewTokenAuth returns a new instance of `TokenAuth`.
+// The store can be nil when only the `LEDGER_AUTH_TOKEN` is used.
+func NewTokenAuth(store models.TokenStore) *TokenAuth {
+	return &TokenAuth{store: store}
+}
+
+// Authenticate returns the identity of the token in the `Authorization` header.
+// The `LEDGER_AUTH_TOKEN` is allowed all the scopes with the identity `default`.
+func (a *TokenAuth) Authenticate(r *http.Request) (*Identity, ledgerError.ApplicationError) {
+	envToken := strings.TrimSpace(os.Getenv("LEDGER_AUTH_TOKEN"))
+
+	// Get the token in the header
+	requestToken := strings.TrimSpace(r.Header.Get("Authorization"))
+	if requestToken == "" {
+		return nil, UnauthorizedError()
+	}
+	if envToken != "" && subtle.ConstantTimeCompare([]byte(requestToken), []byte(envToken)) == 1 {
+		return &Identity{Name: "default", Scopes: []string{models.ScopeAdmin}}, nil
+	}
+	if a.store == nil {
+		return nil, UnauthorizedError()
+	}
+	token, aerr := a.store.GetByToken(requestToken)
+	if aerr != nil {
+		log.Println("Error while getting API token:", aerr)
+		return nil, aerr
+	}
+	if token == nil {
+		return nil, UnauthorizedError()
+	}
+	return &Identity{Name: token.Name, Scopes: token.Scopes, Accounts: token.Accounts}, nil
+}
+
 // TokenAuthMiddleware is a middleware that provides authentication functionality
+// using the `LEDGER_AUTH_TOKEN`. The requests are not authenticated if the token is not set,
+// so the server should not be started without it.
 func TokenAuthMiddleware(handler http.HandlerFunc) http.HandlerFunc {
+	auth := AuthMiddleware(NewTokenAuth(nil), "", handler)
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check whether token authentication enabled
-		envToken := strings.TrimSpace(os.Getenv("LEDGER_AUTH_TOKEN"))
-		if envToken != "" {
-			// Get the token in the header
-			requestToken := strings.TrimSpace(r.Header.Get("Authorization"))
-			// Validate token
-			if requestToken != envToken {
-				w.WriteHeader(http.StatusUnauthorized)
-				return
-			}
+		if strings.TrimSpace(os.Getenv("LEDGER_AUTH_TOKEN")) == "" {
+			handler.ServeHTTP(w, r)
+			return
 		}
-		handler.ServeHTTP(w, r)
+		auth.ServeHTTP(w, r)
 	}
 }
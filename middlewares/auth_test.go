@@ -6,6 +6,8 @@ import (
 	"os"
 	"testing"
 
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
@@ -61,6 +63,102 @@ func (as *AuthSuite) TestInvalidAuth() {
 	rr1 := httptest.NewRecorder()
 	as.handler.ServeHTTP(rr1, req)
 	assert.Equal(t, http.StatusUnauthorized, rr1.Code, "Invalid response code")
+	assert.Contains(t, rr1.Body.String(), `"code":"auth.unauthorized"`, "Invalid error code")
+}
+
+type tokenStore map[string]*models.APIToken
+
+func (s tokenStore) GetByToken(token string) (*models.APIToken, ledgerError.ApplicationError) {
+	return s[token], nil
+}
+
+func (s tokenStore) HasTokens() (bool, ledgerError.ApplicationError) {
+	return len(s) != 0, nil
+}
+
+func (as *AuthSuite) TestScopedAuth() {
+	t := as.T()
+	os.Setenv("LEDGER_AUTH_TOKEN", "XXX")
+
+	auth := NewTokenAuth(tokenStore{
+		"YYY": &models.APIToken{Name: "dashboard", Scopes: []string{models.ScopeAccountsRead}},
+	})
+	var identity *Identity
+	handler := func(scope string) http.HandlerFunc {
+		return AuthMiddleware(auth, scope, func(w http.ResponseWriter, r *http.Request) {
+			identity = RequestIdentity(r)
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	request := func(scope string, token string) *httptest.ResponseRecorder {
+		identity = nil
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", token)
+		rr := httptest.NewRecorder()
+		handler(scope).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request(models.ScopeAccountsRead, "YYY")
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	assert.Equal(t, "dashboard", identity.Name, "Invalid identity")
+
+	rr = request(models.ScopeTransactionsWrite, "YYY")
+	assert.Equal(t, http.StatusForbidden, rr.Code, "Invalid response code")
+	assert.Contains(t, rr.Body.String(), `"code":"auth.forbidden"`, "Invalid error code")
+
+	rr = request(models.ScopeTransactionsWrite, "XXX")
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	assert.Equal(t, "default", identity.Name, "Invalid identity")
+
+	rr = request(models.ScopeAccountsRead, "ZZZ")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+	assert.Nil(t, identity, "Identity should not be authenticated")
+}
+
+func (as *AuthSuite) TestAuthWithoutEnvToken() {
+	t := as.T()
+	os.Unsetenv("LEDGER_AUTH_TOKEN")
+
+	request := func(auth Authenticator, token string) (*httptest.ResponseRecorder, *Identity) {
+		var identity *Identity
+		handler := AuthMiddleware(auth, models.ScopeAccountsRead, func(w http.ResponseWriter, r *http.Request) {
+			identity = RequestIdentity(r)
+			w.WriteHeader(http.StatusOK)
+		})
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Add("Authorization", token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr, identity
+	}
+
+	// Requests are never allowed without any token
+	rr, identity := request(NewTokenAuth(tokenStore{}), "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+	assert.Nil(t, identity, "Identity should not be authenticated")
+	rr, identity = request(NewTokenAuth(nil), "XXX")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+	assert.Nil(t, identity, "Identity should not be authenticated")
+
+	// Only the API tokens are allowed without the `LEDGER_AUTH_TOKEN`
+	auth := NewTokenAuth(tokenStore{
+		"YYY": &models.APIToken{Name: "dashboard", Scopes: []string{models.ScopeAccountsRead}},
+	})
+	rr, identity = request(auth, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+	assert.Nil(t, identity, "Identity should not be authenticated")
+	rr, identity = request(auth, "YYY")
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	assert.Equal(t, "dashboard", identity.Name, "Invalid identity")
 }
 
 func TestAuthSuite(t *testing.T) {
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"crypto/x509"
+	"log"
+	"net/http"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
+)
+
+// CertificateAuth authenticates the requests using the verified TLS client certificates
+type CertificateAuth struct {
+	certificates models.ClientCertificateStore
+}
+
+// NewCertificateAuth returns a new instance of `CertificateAuth`
+func NewCertificateAuth(certificates models.ClientCertificateStore) *CertificateAuth {
+	return &CertificateAuth{certificates: certificates}
+}
+
+// Authenticate returns the identity of the verified client certificate of the request.
+// The SANs of the certificate are matched with the identities before its subject common name.
+func (a *CertificateAuth) Authenticate(r *http.Request) (*Identity, ledgerError.ApplicationError) {
+	certificate := verifiedCertificate(r)
+	if certificate == nil {
+		return nil, CertificateRequiredError()
+	}
+	for _, name := range CertificateNames(certificate) {
+		clientCertificate, aerr := a.certificates.GetByName(name)
+		if aerr != nil {
+			log.Println("Error while getting client certificate:", aerr)
+			return nil, aerr
+		}
+		if clientCertificate != nil {
+			return &Identity{
+				Name:     clientCertificate.Name,
+				Scopes:   clientCertificate.Scopes,
+				Accounts: clientCertificate.Accounts,
+			}, nil
+		}
+	}
+	log.Println("Client certificate has no known identity:", certificate.Subject)
+	return nil, UnauthorizedError()
+}
+
+// CertificateRequiredAuth requires a verified client certificate in the requests
+// before authenticating them using another scheme
+type CertificateRequiredAuth struct {
+	auth Authenticator
+}
+
+// NewCertificateRequiredAuth returns a new instance of `CertificateRequiredAuth` which authenticates using the scheme
+func NewCertificateRequiredAuth(auth Authenticator) *CertificateRequiredAuth {
+	return &CertificateRequiredAuth{auth: auth}
+}
+
+// Authenticate returns the identity of the request authenticated by the scheme, if it has a verified client certificate
+func (a *CertificateRequiredAuth) Authenticate(r *http.Request) (*Identity, ledgerError.ApplicationError) {
+	if verifiedCertificate(r) == nil {
+		return nil, CertificateRequiredError()
+	}
+	return a.auth.Authenticate(r)
+}
+
+// verifiedCertificate returns the verified client certificate of the request, or nil if it doesn't have any
+func verifiedCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// CertificateNames returns the names of the certificate, which are its DNS, URI and email SANs
+// followed by its subject common name
+func CertificateNames(certificate *x509.Certificate) []string {
+	var names []string
+	names = append(names, certificate.DNSNames...)
+	for _, uri := range certificate.URIs {
+		names = append(names, uri.String())
+	}
+	names = append(names, certificate.EmailAddresses...)
+	if certificate.Subject.CommonName != "" {
+		names = append(names, certificate.Subject.CommonName)
+	}
+	return names
+}
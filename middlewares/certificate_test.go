@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type certificateStore map[string]*models.ClientCertificate
+
+func (s certificateStore) GetByName(name string) (*models.ClientCertificate, ledgerError.ApplicationError) {
+	return s[name], nil
+}
+
+func newCertificate(t *testing.T, commonName string, dnsNames ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate
+}
+
+func TestCertificateAuth(t *testing.T) {
+	auth := NewCertificateAuth(certificateStore{
+		"billing.internal": &models.ClientCertificate{Name: "billing.internal", Scopes: []string{models.ScopeTransactionsWrite}},
+		"reports":          &models.ClientCertificate{Name: "reports", Scopes: []string{models.ScopeAccountsRead}},
+	})
+	var identity *Identity
+	handler := AuthMiddleware(auth, "", func(w http.ResponseWriter, r *http.Request) {
+		identity = RequestIdentity(r)
+		w.WriteHeader(http.StatusOK)
+	})
+	request := func(certificate *x509.Certificate) *httptest.ResponseRecorder {
+		identity = nil
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if certificate != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Identity of the SAN
+	rr := request(newCertificate(t, "billing", "billing.internal"))
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	assert.Equal(t, "billing.internal", identity.Name, "Invalid identity")
+
+	// Identity of the subject
+	rr = request(newCertificate(t, "reports"))
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	assert.Equal(t, "reports", identity.Name, "Invalid identity")
+
+	// Unknown identity
+	rr = request(newCertificate(t, "unknown", "unknown.internal"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+
+	// No client certificate
+	rr = request(nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+}
+
+func TestCertificateRequiredAuth(t *testing.T) {
+	auth := NewCertificateRequiredAuth(NewTokenAuth(nil))
+	request := func(certificate *x509.Certificate) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", "XXX")
+		if certificate != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}
+		}
+		rr := httptest.NewRecorder()
+		AuthMiddleware(auth, "", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).ServeHTTP(rr, req)
+		return rr
+	}
+	os.Setenv("LEDGER_AUTH_TOKEN", "XXX")
+
+	// Request with a client certificate is authenticated by the scheme
+	rr := request(newCertificate(t, "reports"))
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+
+	// No client certificate
+	rr = request(nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+	assert.Contains(t, rr.Body.String(), "client certificate", "Invalid error message")
+}
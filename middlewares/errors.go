@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"github.com/RealImage/QLedger/errors"
+)
+
+// UnauthorizedError returns unauthorized request error type
+func UnauthorizedError() errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "auth.unauthorized",
+		Message: "Invalid or missing authorization token",
+	}
+}
+
+// CertificateRequiredError returns the error type of the requests without a verified client certificate
+func CertificateRequiredError() errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "auth.unauthorized",
+		Message: "Invalid or missing client certificate",
+	}
+}
+
+// ForbiddenError returns forbidden request error type
+func ForbiddenError(scope string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "auth.forbidden",
+		Message: "Authorization token is not allowed the scope: " + scope,
+		Details: map[string]interface{}{"scope": scope},
+	}
+}
+
+// SignatureInvalidError returns invalid request signature error type
+func SignatureInvalidError(reason string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "auth.unauthorized",
+		Message: "Invalid request signature: " + reason,
+	}
+}
+
+// NonceLimitError returns the error type of the signed requests denied as too many nonces are remembered
+func NonceLimitError() errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "auth.nonce.limit",
+		Message: "Too many signed requests within the allowed clock skew, please retry later",
+	}
+}
+
+// JWTInvalidError returns invalid JWT bearer token error type
+func JWTInvalidError(err error) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "auth.unauthorized",
+		Message: "Invalid bearer token: " + err.Error(),
+	}
+}
+
+// RouteNotFoundError returns the error type of the requests to the paths without any route
+func RouteNotFoundError(path string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "route.notfound",
+		Message: "Route doesn't exist: " + path,
+		Details: map[string]interface{}{"path": path},
+	}
+}
+
+// MethodNotAllowedError returns the error type of the requests with the methods not allowed by the route
+func MethodNotAllowedError(method string, path string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "route.method.invalid",
+		Message: "Method not allowed: " + method + " " + path,
+		Details: map[string]interface{}{"method": method, "path": path},
+	}
+}
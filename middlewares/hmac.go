@@ -0,0 +1,151 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RealImage/QLedger/client"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
+)
+
+// DefaultHMACSkew is the default clock skew allowed between the signed timestamp and the server time
+const DefaultHMACSkew = 5 * time.Minute
+
+// DefaultHMACMaxNonces is the default limit of the nonces remembered within the allowed clock skew
+const DefaultHMACMaxNonces = 100000
+
+// DefaultHMACMaxBodyBytes is the default limit of the size of the signed request body, which is read in the memory to verify its digest
+const DefaultHMACMaxBodyBytes = 10 << 20
+
+// HMACAuth authenticates the requests signed using HMAC-SHA256 with the secret of the named keys.
+// The signature covers the method, path, timestamp, nonce and body digest of the request,
+// and the same nonce is not accepted again within the allowed clock skew.
+// The nonces are remembered in the memory of the process, so the replays are denied only by the same instance.
+type HMACAuth struct {
+	keys         models.HMACKeyStore
+	skew         time.Duration
+	maxBodyBytes int64
+	nonces       *nonceCache
+	now          func() time.Time
+}
+
+// NewHMACAuth returns a new instance of `HMACAuth` that allows the clock skew
+func NewHMACAuth(keys models.HMACKeyStore, skew time.Duration) *HMACAuth {
+	return &HMACAuth{
+		keys:         keys,
+		skew:         skew,
+		maxBodyBytes: DefaultHMACMaxBodyBytes,
+		nonces:       newNonceCache(DefaultHMACMaxNonces),
+		now:          time.Now,
+	}
+}
+
+// WithMaxBodyBytes returns a copy of the `HMACAuth` which denies the signed requests having the body larger than the limit
+func (a *HMACAuth) WithMaxBodyBytes(maxBodyBytes int64) *HMACAuth {
+	auth := *a
+	auth.maxBodyBytes = maxBodyBytes
+	return &auth
+}
+
+// Authenticate returns the identity of the key that signed the request
+func (a *HMACAuth) Authenticate(r *http.Request) (*Identity, ledgerError.ApplicationError) {
+	name := r.Header.Get(client.HeaderKey)
+	timestamp := r.Header.Get(client.HeaderTimestamp)
+	nonce := r.Header.Get(client.HeaderNonce)
+	signature := r.Header.Get(client.HeaderSignature)
+	if name == "" || timestamp == "" || nonce == "" || signature == "" {
+		return nil, UnauthorizedError()
+	}
+
+	key, aerr := a.keys.GetByName(name)
+	if aerr != nil {
+		log.Println("Error while getting HMAC key:", aerr)
+		return nil, aerr
+	}
+	if key == nil {
+		return nil, SignatureInvalidError("Unknown key: " + name)
+	}
+
+	// Check whether the request is signed recently
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, SignatureInvalidError("Invalid timestamp: " + timestamp)
+	}
+	now := a.now()
+	signedAt := time.Unix(unix, 0)
+	if signedAt.Before(now.Add(-a.skew)) || signedAt.After(now.Add(a.skew)) {
+		return nil, SignatureInvalidError("Timestamp is not within the allowed clock skew: " + timestamp)
+	}
+
+	// Read the body up to the limit to verify its digest, and replace it for the handlers
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, a.maxBodyBytes+1))
+	if err != nil {
+		return nil, SignatureInvalidError("Unable to read the body: " + err.Error())
+	}
+	if int64(len(body)) > a.maxBodyBytes {
+		return nil, SignatureInvalidError("Body is larger than " + strconv.FormatInt(a.maxBodyBytes, 10) + " bytes")
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	stringToSign := client.StringToSign(r.Method, r.URL.RequestURI(), timestamp, nonce, client.BodyDigest(body))
+	expected := client.Signature(key.Secret, stringToSign)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, SignatureInvalidError("Signature doesn't match")
+	}
+
+	// The nonce is remembered only for the valid signatures,
+	// until the timestamp is no longer within the allowed clock skew
+	if aerr := a.nonces.add(name+":"+nonce, signedAt.Add(a.skew), now); aerr != nil {
+		log.Println("Nonce is not accepted:", aerr)
+		return nil, aerr
+	}
+	return &Identity{Name: key.Name, Scopes: key.Scopes, Accounts: key.Accounts}, nil
+}
+
+// nonceCache remembers the nonces until their expiry, up to the limit
+type nonceCache struct {
+	sync.Mutex
+	expiries map[string]time.Time
+	sweptAt  time.Time
+	limit    int
+}
+
+// newNonceCache returns a new instance of `nonceCache` which remembers at most the limit of nonces
+func newNonceCache(limit int) *nonceCache {
+	return &nonceCache{expiries: make(map[string]time.Time), limit: limit}
+}
+
+// add adds the nonce, and returns an error if the nonce is already added and not expired.
+// The nonces are not accepted while the cache is full of the nonces not expired,
+// as forgetting any of them would allow its replay.
+func (c *nonceCache) add(nonce string, expiry time.Time, now time.Time) ledgerError.ApplicationError {
+	c.Lock()
+	defer c.Unlock()
+	// Remove the expired nonces at most once a minute, or once a second when the cache is full
+	full := len(c.expiries) >= c.limit
+	if now.Sub(c.sweptAt) > time.Minute || (full && now.Sub(c.sweptAt) > time.Second) {
+		for n, e := range c.expiries {
+			if e.Before(now) {
+				delete(c.expiries, n)
+			}
+		}
+		c.sweptAt = now
+	}
+	if e, ok := c.expiries[nonce]; ok && !e.Before(now) {
+		return SignatureInvalidError("Nonce is already used: " + nonce)
+	}
+	if len(c.expiries) >= c.limit {
+		return NonceLimitError()
+	}
+	c.expiries[nonce] = expiry
+	return nil
+}
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RealImage/QLedger/client"
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type hmacKeyStore map[string]*models.HMACKey
+
+func (s hmacKeyStore) GetByName(name string) (*models.HMACKey, ledgerError.ApplicationError) {
+	return s[name], nil
+}
+
+func TestHMACAuth(t *testing.T) {
+	auth := NewHMACAuth(hmacKeyStore{
+		"partner42": &models.HMACKey{Name: "partner42", Secret: "XXX", Scopes: []string{models.ScopeTransactionsWrite}},
+	}, time.Minute)
+	var body string
+	handler := AuthMiddleware(auth, models.ScopeTransactionsWrite, func(w http.ResponseWriter, r *http.Request) {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r.Body)
+		body = buf.String()
+		w.WriteHeader(http.StatusOK)
+	})
+	signedRequest := func(payload string, secret string) *http.Request {
+		req, err := http.NewRequest("POST", "/v1/transactions", bytes.NewBufferString(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Sign(req, "partner42", secret); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Valid signature
+	req := signedRequest(`{"id": "t001"}`, "XXX")
+	rr := serve(req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	assert.Equal(t, `{"id": "t001"}`, body, "Body should be available to the handler")
+
+	// Replayed request
+	req.Body = ioutil.NopCloser(bytes.NewBufferString(`{"id": "t001"}`))
+	rr = serve(req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Replayed request should be denied")
+
+	// Tampered body
+	req = signedRequest(`{"id": "t001"}`, "XXX")
+	req.Body = ioutil.NopCloser(bytes.NewBufferString(`{"id": "t002"}`))
+	rr = serve(req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Tampered request should be denied")
+
+	// Invalid secret
+	rr = serve(signedRequest(`{"id": "t001"}`, "XYZ"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid signature should be denied")
+
+	// Body larger than the limit
+	auth.maxBodyBytes = 16
+	rr = serve(signedRequest(`{"id": "t001", "data": {}}`, "XXX"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Large body should be denied")
+	assert.Contains(t, rr.Body.String(), "larger than 16 bytes", "Invalid error message")
+	rr = serve(signedRequest(`{"id": "t002"}`, "XXX"))
+	assert.Equal(t, http.StatusOK, rr.Code, "Body within the limit should be allowed")
+	auth.maxBodyBytes = DefaultHMACMaxBodyBytes
+
+	// Expired timestamp
+	auth.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	rr = serve(signedRequest(`{"id": "t001"}`, "XXX"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Expired signature should be denied")
+	assert.Contains(t, rr.Body.String(), "clock skew", "Invalid error message")
+}
+
+func TestNonceCache(t *testing.T) {
+	cache := newNonceCache(2)
+	now := time.Now()
+	assert.Nil(t, cache.add("k:n1", now.Add(time.Minute), now), "Nonce should be added")
+	aerr := cache.add("k:n1", now.Add(time.Minute), now)
+	assert.NotNil(t, aerr, "Nonce should not be added again")
+	assert.Equal(t, "auth.unauthorized", aerr.ErrorCode(), "Invalid error code")
+
+	// The nonces are denied while the cache is full of the nonces not expired
+	assert.Nil(t, cache.add("k:n2", now.Add(2*time.Minute), now), "Nonce should be added")
+	aerr = cache.add("k:n3", now.Add(time.Minute), now)
+	assert.NotNil(t, aerr, "Nonce should not be added to the full cache")
+	assert.Equal(t, "auth.nonce.limit", aerr.ErrorCode(), "Invalid error code")
+
+	// The expired nonces are removed once the cache is full
+	later := now.Add(90 * time.Second)
+	assert.Nil(t, cache.add("k:n3", later.Add(time.Minute), later), "Nonce should be added")
+	assert.Equal(t, 2, len(cache.expiries), "Expired nonce should be removed")
+	assert.NotNil(t, cache.add("k:n2", later.Add(time.Minute), later), "Nonce not expired should be remembered")
+}
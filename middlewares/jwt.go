@@ -0,0 +1,251 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/RealImage/QLedger/models"
+)
+
+// JWTKeySet holds the public keys used to verify the JWTs by their key IDs
+type JWTKeySet map[string]crypto.PublicKey
+
+// jwk represents a public key in the JSON Web Key format
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses the RSA and EC P-256 public keys of the JSON Web Key Set
+func ParseJWKS(data []byte) (JWTKeySet, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(JWTKeySet, len(jwks.Keys))
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			n, err := decodeBigInt(key.N)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid RSA key: %v (%v)", key.Kid, err)
+			}
+			e, err := decodeBigInt(key.E)
+			if err != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("Invalid RSA key: %v", key.Kid)
+			}
+			keys[key.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if key.Crv != "P-256" {
+				return nil, fmt.Errorf("Unsupported EC curve: %v (%v)", key.Kid, key.Crv)
+			}
+			x, err := decodeBigInt(key.X)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid EC key: %v (%v)", key.Kid, err)
+			}
+			y, err := decodeBigInt(key.Y)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid EC key: %v (%v)", key.Kid, err)
+			}
+			if !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("Invalid EC key: %v", key.Kid)
+			}
+			keys[key.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		default:
+			return nil, fmt.Errorf("Unsupported key type: %v (%v)", key.Kid, key.Kty)
+		}
+	}
+	return keys, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// JWTConfig configures the validation of the JWTs and the mapping of their claims
+type JWTConfig struct {
+	// Issuer and Audience are validated only if they are set
+	Issuer   string
+	Audience string
+	// ScopesClaim has the ledger scopes as a space separated string or a list
+	ScopesClaim string
+	// AccountsClaim has the list of the allowed account ID prefixes
+	AccountsClaim string
+	// Leeway is allowed in validating the expiry and not before times
+	Leeway time.Duration
+}
+
+// JWTAuth authenticates the requests with the RS256 or ES256 signed JWT bearer tokens
+type JWTAuth struct {
+	keys   JWTKeySet
+	config JWTConfig
+	now    func() time.Time
+}
+
+// NewJWTAuth returns a new instance of `JWTAuth`
+func NewJWTAuth(keys JWTKeySet, config JWTConfig) *JWTAuth {
+	return &JWTAuth{keys: keys, config: config, now: time.Now}
+}
+
+// Authenticate returns the identity of the subject of the JWT in the `Authorization` header.
+// The ledger scopes and the allowed account prefixes of the identity are taken from the claims.
+func (a *JWTAuth) Authenticate(r *http.Request) (*Identity, ledgerError.ApplicationError) {
+	authorization := strings.TrimSpace(r.Header.Get("Authorization"))
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, UnauthorizedError()
+	}
+	claims, err := a.verify(strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer ")))
+	if err != nil {
+		return nil, JWTInvalidError(err)
+	}
+	if err := a.validateClaims(claims); err != nil {
+		return nil, JWTInvalidError(err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, JWTInvalidError(fmt.Errorf("Missing subject"))
+	}
+	identity := &Identity{Name: subject}
+	for _, scope := range claimStrings(claims[a.config.ScopesClaim]) {
+		if models.ValidateScopes([]string{scope}) == nil {
+			identity.Scopes = append(identity.Scopes, scope)
+		}
+	}
+	for _, prefix := range claimStrings(claims[a.config.AccountsClaim]) {
+		identity.Accounts = append(identity.Accounts, prefix+"*")
+	}
+	return identity, nil
+}
+
+// verify verifies the signature of the JWT and returns its claims
+func (a *JWTAuth) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("Malformed header")
+	}
+	key, ok := a.keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key: %v", header.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Malformed signature")
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch header.Alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return nil, fmt.Errorf("Invalid signature")
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return nil, fmt.Errorf("Invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return nil, fmt.Errorf("Invalid signature")
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported algorithm: %v", header.Alg)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("Malformed claims")
+	}
+	return claims, nil
+}
+
+// validateClaims validates the time, issuer and audience claims
+func (a *JWTAuth) validateClaims(claims map[string]interface{}) error {
+	now := a.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("Missing expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(a.config.Leeway)) {
+		return fmt.Errorf("Token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-a.config.Leeway)) {
+		return fmt.Errorf("Token is not valid yet")
+	}
+	if a.config.Issuer != "" && claims["iss"] != a.config.Issuer {
+		return fmt.Errorf("Invalid issuer")
+	}
+	if a.config.Audience != "" {
+		valid := false
+		for _, audience := range claimStrings(claims["aud"]) {
+			if audience == a.config.Audience {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("Invalid audience")
+		}
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// claimStrings returns the values of a claim, which is either a space separated string or a list of strings
+func claimStrings(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
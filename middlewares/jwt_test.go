@@ -0,0 +1,153 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RealImage/QLedger/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signJWT(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	payload := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(payload))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(signature[32-len(rBytes):32], rBytes)
+		copy(signature[64-len(sBytes):], sBytes)
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodeInt := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kid": "rsa1", "kty": "RSA", "use": "sig", "n": encodeInt(rsaKey.N), "e": encodeInt(big.NewInt(int64(rsaKey.E)))},
+			{"kid": "ec1", "kty": "EC", "crv": "P-256", "x": encodeInt(ecKey.X), "y": encodeInt(ecKey.Y)},
+		},
+	})
+	keys, err := ParseJWKS(jwks)
+	assert.Equal(t, nil, err, "Error while parsing JWKS")
+	assert.Equal(t, 2, len(keys), "Invalid number of keys")
+
+	auth := NewJWTAuth(keys, JWTConfig{
+		Issuer:        "https://idp.example.com",
+		Audience:      "qledger",
+		ScopesClaim:   "scope",
+		AccountsClaim: "ledger_accounts",
+	})
+	var identity *Identity
+	handler := AuthMiddleware(auth, models.ScopeTransactionsRead, func(w http.ResponseWriter, r *http.Request) {
+		identity = RequestIdentity(r)
+		w.WriteHeader(http.StatusOK)
+	})
+	request := func(token string) *httptest.ResponseRecorder {
+		identity = nil
+		req, err := http.NewRequest("GET", "/v1/transactions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	claims := func(exp time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			"sub":             "reporting-service",
+			"iss":             "https://idp.example.com",
+			"aud":             []string{"qledger", "billing"},
+			"exp":             exp.Unix(),
+			"scope":           "openid transactions:read",
+			"ledger_accounts": []string{"PARTNER42."},
+		}
+	}
+	validClaims := claims(time.Now().Add(time.Hour))
+
+	// RS256 token
+	rr := request(signJWT(t, "RS256", "rsa1", rsaKey, validClaims))
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+	assert.Equal(t, "reporting-service", identity.Name, "Invalid identity")
+	assert.Equal(t, []string{models.ScopeTransactionsRead}, identity.Scopes, "Invalid scopes")
+	assert.Equal(t, models.AccountPatterns{"PARTNER42.*"}, identity.Accounts, "Invalid accounts")
+
+	// ES256 token
+	rr = request(signJWT(t, "ES256", "ec1", ecKey, validClaims))
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+
+	// Algorithm not matching the key
+	rr = request(signJWT(t, "ES256", "rsa1", rsaKey, validClaims))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+
+	// Unsigned token
+	rr = request(encodeSegment(t, map[string]string{"alg": "none", "kid": "rsa1"}) + "." + encodeSegment(t, validClaims) + ".")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+
+	// Tampered claims
+	parts := strings.Split(signJWT(t, "RS256", "rsa1", rsaKey, validClaims), ".")
+	tamperedClaims := claims(time.Now().Add(time.Hour))
+	tamperedClaims["scope"] = "admin"
+	rr = request(parts[0] + "." + encodeSegment(t, tamperedClaims) + "." + parts[2])
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+
+	// Expired token
+	rr = request(signJWT(t, "RS256", "rsa1", rsaKey, claims(time.Now().Add(-time.Hour))))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+	assert.Contains(t, rr.Body.String(), "expired", "Invalid error message")
+
+	// Invalid audience
+	otherClaims := claims(time.Now().Add(time.Hour))
+	otherClaims["aud"] = "billing"
+	rr = request(signJWT(t, "RS256", "rsa1", rsaKey, otherClaims))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Invalid response code")
+
+	// Token without the scope
+	otherClaims = claims(time.Now().Add(time.Hour))
+	otherClaims["scope"] = "accounts:read"
+	rr = request(signJWT(t, "RS256", "rsa1", rsaKey, otherClaims))
+	assert.Equal(t, http.StatusForbidden, rr.Code, "Invalid response code")
+}
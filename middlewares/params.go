@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/julienschmidt/httprouter"
+)
+
+type paramsKey struct{}
+
+// ParamsMiddleware is a middleware that makes the route parameters available to the `http.HandlerFunc`
+func ParamsMiddleware(handler http.HandlerFunc) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		ctx := context.WithValue(r.Context(), paramsKey{}, params)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// Params returns the route parameters of the request
+func Params(r *http.Request) httprouter.Params {
+	params, _ := r.Context().Value(paramsKey{}).(httprouter.Params)
+	return params
+}
+
+// ParamRoutes is a middleware that dispatches the request to a handler based on the value of a route parameter.
+// It helps static routes like `/v1/transactions/_search` to coexist with wildcard routes like
+// `/v1/transactions/:id/reverse`, as httprouter doesn't allow registering both of them.
+// Each of the handlers is a route on its own, which authenticates the request with the scope of the route.
+// The other values of the parameter result in the same error as the paths without any route.
+func ParamRoutes(name string, routes map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := routes[Params(r).ByName(name)]
+		if !ok {
+			NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}
+}
+
+// NotFound writes the error response of the requests to the paths without any route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	ledgerError.WriteResponse(w, RouteNotFoundError(r.URL.Path))
+}
+
+// MethodNotAllowed writes the error response of the requests with the methods not allowed by the route
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	ledgerError.WriteResponse(w, MethodNotAllowedError(r.Method, r.URL.Path))
+}
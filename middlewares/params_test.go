@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParamRoutes(t *testing.T) {
+	router := httprouter.New()
+	router.Handle(http.MethodPost, "/v1/transactions/:id", ParamsMiddleware(ParamRoutes("id", map[string]http.HandlerFunc{
+		"_search": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	})))
+	router.Handle(http.MethodPost, "/v1/transactions/:id/reverse", ParamsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "t001", Params(r).ByName("id"), "Invalid route parameter")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req, err := http.NewRequest("POST", "/v1/transactions/_search", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid response code")
+
+	req, err = http.NewRequest("POST", "/v1/transactions/t001/reverse", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code, "Invalid response code")
+
+	req, err = http.NewRequest("POST", "/v1/transactions/t001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "Invalid response code")
+	var response ledgerError.Response
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.Nil(t, err, "Invalid json response: "+rr.Body.String())
+	assert.Equal(t, "route.notfound", response.Code, "Invalid error code")
+	assert.Equal(t, "/v1/transactions/t001", response.Details.(map[string]interface{})["path"], "Invalid error details")
+}
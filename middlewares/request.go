@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// RequestIDHeader is the header having the ID of the request, which is echoed back in the response
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID restricts the request IDs given by the callers to a safe length and characters
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// RequestIDMiddleware is a middleware that identifies the request by the ID in its `X-Request-ID` header,
+// or a newly generated ID if the header is missing or invalid
+func RequestIDMiddleware(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// RequestID returns the ID of the request
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
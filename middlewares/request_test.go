@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var requestID string
+	handler := RequestIDMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		requestID = RequestID(r)
+	})
+
+	req, err := http.NewRequest("GET", "/v1/accounts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(RequestIDHeader, "req-001")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "req-001", requestID, "Invalid request ID")
+	assert.Equal(t, "req-001", rr.Header().Get(RequestIDHeader), "Invalid request ID header")
+
+	req.Header.Set(RequestIDHeader, "req 001\n")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Len(t, requestID, 32, "Request ID should be generated")
+	assert.Equal(t, requestID, rr.Header().Get(RequestIDHeader), "Invalid request ID header")
+
+	req.Header.Del(RequestIDHeader)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Len(t, requestID, 32, "Request ID should be generated")
+	assert.NotEqual(t, "", rr.Header().Get(RequestIDHeader), "Request ID header should be set")
+}
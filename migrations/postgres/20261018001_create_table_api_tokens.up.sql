@@ -0,0 +1,5 @@
+CREATE TABLE api_tokens (
+    name character varying NOT NULL,
+    token_hash character varying NOT NULL,
+    scopes character varying[] DEFAULT '{}'::character varying[] NOT NULL
+);
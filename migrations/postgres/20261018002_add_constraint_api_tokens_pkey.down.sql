@@ -0,0 +1,2 @@
+ALTER TABLE ONLY api_tokens
+    DROP CONSTRAINT IF EXISTS api_tokens_pkey;
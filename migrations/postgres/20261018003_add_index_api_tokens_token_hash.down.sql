@@ -0,0 +1 @@
+DROP INDEX IF EXISTS api_tokens_token_hash_idx;
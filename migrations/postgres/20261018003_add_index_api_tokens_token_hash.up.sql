@@ -0,0 +1 @@
+CREATE UNIQUE INDEX api_tokens_token_hash_idx ON api_tokens USING btree (token_hash);
@@ -0,0 +1 @@
+ALTER TABLE api_tokens ADD COLUMN accounts character varying[] DEFAULT '{}'::character varying[] NOT NULL;
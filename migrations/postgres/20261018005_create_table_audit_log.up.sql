@@ -0,0 +1,12 @@
+CREATE TABLE audit_log (
+    id bigint NOT NULL,
+    "timestamp" timestamp without time zone DEFAULT timezone('utc'::text, now()) NOT NULL,
+    identity character varying DEFAULT ''::character varying NOT NULL,
+    request_id character varying DEFAULT ''::character varying NOT NULL,
+    endpoint character varying DEFAULT ''::character varying NOT NULL,
+    entity_type character varying NOT NULL,
+    entity_id character varying NOT NULL,
+    action character varying NOT NULL,
+    before jsonb,
+    after jsonb
+);
@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS audit_log_id_seq;
@@ -0,0 +1,7 @@
+CREATE SEQUENCE audit_log_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1
+    OWNED BY audit_log.id;
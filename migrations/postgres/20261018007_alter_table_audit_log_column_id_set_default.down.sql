@@ -0,0 +1 @@
+ALTER TABLE ONLY audit_log ALTER COLUMN id DROP DEFAULT;
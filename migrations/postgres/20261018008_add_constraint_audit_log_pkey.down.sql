@@ -0,0 +1 @@
+ALTER TABLE ONLY audit_log DROP CONSTRAINT IF EXISTS audit_log_pkey;
@@ -0,0 +1 @@
+CREATE INDEX audit_log_entity_idx ON audit_log USING btree (entity_type, entity_id);
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN version bigint DEFAULT 1 NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE transactions ADD COLUMN version bigint DEFAULT 1 NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE ONLY account_versions
+    ADD CONSTRAINT account_versions_pkey PRIMARY KEY (account_id, version);
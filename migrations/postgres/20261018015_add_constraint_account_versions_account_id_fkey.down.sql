@@ -0,0 +1,2 @@
+ALTER TABLE ONLY account_versions
+    DROP CONSTRAINT IF EXISTS account_versions_account_id_fkey;
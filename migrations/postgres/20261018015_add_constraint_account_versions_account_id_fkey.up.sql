@@ -0,0 +1,2 @@
+ALTER TABLE ONLY account_versions
+    ADD CONSTRAINT account_versions_account_id_fkey FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE;
@@ -0,0 +1,6 @@
+CREATE TABLE transaction_versions (
+    transaction_id character varying NOT NULL,
+    version bigint NOT NULL,
+    "timestamp" timestamp without time zone DEFAULT timezone('utc'::text, now()) NOT NULL,
+    data jsonb DEFAULT '{}'::jsonb NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE ONLY transaction_versions
+    ADD CONSTRAINT transaction_versions_pkey PRIMARY KEY (transaction_id, version);
@@ -0,0 +1,2 @@
+ALTER TABLE ONLY transaction_versions
+    DROP CONSTRAINT IF EXISTS transaction_versions_transaction_id_fkey;
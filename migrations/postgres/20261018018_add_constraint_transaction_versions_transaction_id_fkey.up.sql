@@ -0,0 +1,2 @@
+ALTER TABLE ONLY transaction_versions
+    ADD CONSTRAINT transaction_versions_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE;
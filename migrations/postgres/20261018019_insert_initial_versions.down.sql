@@ -0,0 +1 @@
+TRUNCATE account_versions, transaction_versions;
@@ -0,0 +1,2 @@
+INSERT INTO account_versions (account_id, version, data) SELECT id, version, data FROM accounts;
+INSERT INTO transaction_versions (transaction_id, version, "timestamp", data) SELECT id, version, "timestamp", data FROM transactions;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS lines_account_id_pattern_idx;
\ No newline at end of file
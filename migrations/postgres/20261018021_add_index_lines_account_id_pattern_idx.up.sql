@@ -0,0 +1 @@
+CREATE INDEX lines_account_id_pattern_idx ON lines USING btree (account_id varchar_pattern_ops);
\ No newline at end of file
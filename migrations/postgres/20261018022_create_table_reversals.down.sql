@@ -0,0 +1 @@
+DROP TABLE IF EXISTS reversals;
\ No newline at end of file
@@ -0,0 +1,4 @@
+CREATE TABLE reversals (
+    transaction_id character varying NOT NULL,
+    reversal_id character varying NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE ONLY reversals
+    DROP CONSTRAINT IF EXISTS reversals_pkey;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE ONLY reversals
+    ADD CONSTRAINT reversals_pkey PRIMARY KEY (transaction_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE ONLY reversals
+    ADD CONSTRAINT reversals_reversal_id_key UNIQUE (reversal_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE ONLY reversals
+    DROP CONSTRAINT IF EXISTS reversals_reversal_id_fkey;
\ No newline at end of file
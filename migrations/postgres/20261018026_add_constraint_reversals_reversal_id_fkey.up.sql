@@ -0,0 +1,2 @@
+ALTER TABLE ONLY reversals
+    ADD CONSTRAINT reversals_reversal_id_fkey FOREIGN KEY (reversal_id) REFERENCES transactions(id) ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1 @@
+TRUNCATE reversals;
\ No newline at end of file
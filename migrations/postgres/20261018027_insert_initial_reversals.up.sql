@@ -0,0 +1,4 @@
+INSERT INTO reversals (transaction_id, reversal_id)
+    SELECT transactions.id, reversals.id FROM transactions JOIN transactions AS reversals
+        ON (reversals.id = transactions.data->>'reversed_by' AND reversals.data->>'reverses' = transactions.id)
+    ON CONFLICT DO NOTHING;
\ No newline at end of file
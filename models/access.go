@@ -0,0 +1,100 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// AccountPatterns restricts the access to the accounts with IDs matching any of the patterns.
+// The `*` in a pattern matches any sequence of characters, like `PARTNER42.*`.
+// The empty list of patterns doesn't restrict any account.
+type AccountPatterns []string
+
+// IsRestricted says whether the access is restricted to some accounts
+func (p AccountPatterns) IsRestricted() bool {
+	return len(p) != 0
+}
+
+// AccountFilter matches the account IDs against the account patterns, which are compiled once.
+// The nil filter doesn't restrict any account.
+type AccountFilter struct {
+	patterns AccountPatterns
+	matcher  *regexp.Regexp
+}
+
+// NewAccountFilter returns the filter of the account patterns, or nil if the patterns don't restrict any account
+func NewAccountFilter(patterns AccountPatterns) *AccountFilter {
+	if !patterns.IsRestricted() {
+		return nil
+	}
+	alternatives := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		alternatives = append(alternatives, strings.Join(parts, ".*"))
+	}
+	return &AccountFilter{
+		patterns: patterns,
+		matcher:  regexp.MustCompile("^(?:" + strings.Join(alternatives, "|") + ")$"),
+	}
+}
+
+// Patterns returns the account patterns of the filter
+func (f *AccountFilter) Patterns() AccountPatterns {
+	if f == nil {
+		return nil
+	}
+	return f.patterns
+}
+
+// IsRestricted says whether the access is restricted to some accounts
+func (f *AccountFilter) IsRestricted() bool {
+	return f != nil
+}
+
+// Allows says whether the account ID matches any of the patterns
+func (f *AccountFilter) Allows(id string) bool {
+	return f == nil || f.matcher.MatchString(id)
+}
+
+// AllowsAny says whether any of the lines of a transaction is allowed
+func (f *AccountFilter) AllowsAny(lines []*TransactionLineResult) bool {
+	if f == nil {
+		return true
+	}
+	for _, line := range lines {
+		if f.Allows(line.AccountID) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterLines returns the lines of a transaction having the allowed accounts,
+// so that the accounts and deltas of the other lines are hidden
+func (f *AccountFilter) FilterLines(lines []*TransactionLineResult) []*TransactionLineResult {
+	if f == nil {
+		return lines
+	}
+	allowed := make([]*TransactionLineResult, 0, len(lines))
+	for _, line := range lines {
+		if f.Allows(line.AccountID) {
+			allowed = append(allowed, line)
+		}
+	}
+	return allowed
+}
+
+// toSQL returns the SQL condition on the column having account IDs, which matches any of the patterns
+func (p AccountPatterns) toSQL(column string) (condition string, args []interface{}) {
+	likeEscaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	var conditions []string
+	for _, pattern := range p {
+		conditions = append(conditions, column+" LIKE ?")
+		args = append(args, likeEscaper.Replace(pattern))
+	}
+	condition = "(" + strings.Join(conditions, " OR ") + ")"
+	return
+}
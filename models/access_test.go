@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountPatterns(t *testing.T) {
+	var unrestricted AccountPatterns
+	assert.Equal(t, false, unrestricted.IsRestricted(), "Patterns should not be restricted")
+	assert.Nil(t, NewAccountFilter(unrestricted), "Filter should not be restricted")
+	assert.Equal(t, true, NewAccountFilter(unrestricted).Allows("alice"), "Account should be allowed")
+
+	accounts := NewAccountFilter(AccountPatterns{"PARTNER42.*", "bob"})
+	assert.Equal(t, true, accounts.IsRestricted(), "Patterns should be restricted")
+	assert.Equal(t, AccountPatterns{"PARTNER42.*", "bob"}, accounts.Patterns(), "Invalid patterns")
+	assert.Equal(t, true, accounts.Allows("PARTNER42.cash"), "Account should be allowed")
+	assert.Equal(t, true, accounts.Allows("bob"), "Account should be allowed")
+	assert.Equal(t, false, accounts.Allows("PARTNER420.cash"), "Account should not be allowed")
+	assert.Equal(t, false, accounts.Allows("alice"), "Account should not be allowed")
+	assert.Equal(t, false, accounts.Allows("bobby"), "Account should not be allowed")
+	lines := []*TransactionLineResult{
+		&TransactionLineResult{AccountID: "alice"},
+		&TransactionLineResult{AccountID: "bob"},
+	}
+	assert.Equal(t, true, accounts.AllowsAny(lines), "Lines should be allowed")
+	assert.Equal(t, []*TransactionLineResult{lines[1]}, accounts.FilterLines(lines), "Only the allowed lines should remain")
+	assert.Equal(t, lines, NewAccountFilter(nil).FilterLines(lines), "Lines should not be filtered")
+
+	condition, args := AccountPatterns{"PARTNER_42.*", "100%"}.toSQL("id")
+	assert.Equal(t, "(id LIKE ? OR id LIKE ?)", condition, "Invalid SQL condition")
+	assert.Equal(t, []interface{}{`PARTNER\_42.%`, `100\%`}, args, "Invalid SQL args")
+}
+
+func TestSearchRestrictedAccounts(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"query": {"must": {"terms": [{"status": "active"}]}}}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+	rawQuery.accounts = AccountPatterns{"PARTNER42.*"}
+
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceAccounts)
+	assert.Contains(t, sqlQuery.sql, "WHERE ((id LIKE $1) AND (data #> $2::text[] @> $3::jsonb))", "Invalid SQL query")
+	assert.Equal(t, []interface{}{"PARTNER42.%", pq.Array([]string{"status"}), `"active"`}, sqlQuery.args, "Invalid SQL args")
+
+	sqlQuery = rawQuery.ToSQLQuery(SearchNamespaceTransactions)
+	assert.Contains(t, sqlQuery.sql, "WHERE (id IN (SELECT lines.transaction_id FROM lines WHERE (lines.account_id LIKE $1))", "Invalid SQL query")
+}
@@ -13,11 +13,15 @@ type Account struct {
 	ID      string                 `json:"id"`
 	Balance int                    `json:"balance"`
 	Data    map[string]interface{} `json:"data"`
+	// ExpectedVersion is the version the update of the account expects, unless it is 0
+	ExpectedVersion int64 `json:"expected_version,omitempty"`
 }
 
 // AccountDB provides all functions related to ledger account
 type AccountDB struct {
-	db *sql.DB
+	db      *sql.DB
+	audit   *AuditInfo
+	schemas *DataSchemas
 }
 
 // NewAccountDB provides instance of `AccountDB`
@@ -25,6 +29,18 @@ func NewAccountDB(db *sql.DB) AccountDB {
 	return AccountDB{db: db}
 }
 
+// WithAudit returns a copy of the `AccountDB` which records its changes in the audit log as made by the caller
+func (a AccountDB) WithAudit(audit *AuditInfo) AccountDB {
+	a.audit = audit
+	return a
+}
+
+// WithSchemas returns a copy of the `AccountDB` which validates the patched data against the schemas
+func (a AccountDB) WithSchemas(schemas *DataSchemas) AccountDB {
+	a.schemas = schemas
+	return a
+}
+
 // GetByID returns an acccount with the given ID
 func (a *AccountDB) GetByID(id string) (*Account, ledgerError.ApplicationError) {
 	account := &Account{ID: id}
@@ -40,6 +56,26 @@ func (a *AccountDB) GetByID(id string) (*Account, ledgerError.ApplicationError)
 	return account, nil
 }
 
+// GetResultByID returns an account with the given ID in the format of `AccountResult`.
+// The balance is computed as of the given timestamp, unless it is empty.
+func (a *AccountDB) GetResultByID(id string, asOf string) (*AccountResult, ledgerError.ApplicationError) {
+	var row *sql.Row
+	if asOf == "" {
+		row = a.db.QueryRow(accountsSelectSQL+" WHERE id=$1", id)
+	} else {
+		row = a.db.QueryRow(enumerateSQLPlacholder(accountsAsOfSelectSQL+" WHERE id=?"), asOf, id)
+	}
+	account, err := scanAccountResult(row)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, AccountNotFoundError(id)
+	case err != nil:
+		return nil, DBError(err)
+	}
+
+	return account, nil
+}
+
 // IsExists says whether an account exists or not
 func (a *AccountDB) IsExists(id string) (bool, ledgerError.ApplicationError) {
 	var exists bool
@@ -63,16 +99,35 @@ func (a *AccountDB) CreateAccount(account *Account) ledgerError.ApplicationError
 		accountData = string(data)
 	}
 
+	tx, err := a.db.Begin()
+	if err != nil {
+		return DBError(err)
+	}
+	defer tx.Rollback()
+
 	q := "INSERT INTO accounts (id, data)  VALUES ($1, $2)"
-	_, err = a.db.Exec(q, account.ID, accountData)
+	_, err = tx.Exec(q, account.ID, accountData)
+	if err != nil {
+		return DBError(err)
+	}
+	err = recordVersion(tx, AuditEntityAccount, account.ID)
+	if err != nil {
+		return DBError(err)
+	}
+	err = writeAudit(tx, a.audit, AuditEntityAccount, account.ID, AuditActionCreate, "", accountData)
 	if err != nil {
 		return DBError(err)
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return DBError(err)
+	}
 	return nil
 }
 
-// UpdateAccount updates the account with new data
+// UpdateAccount updates the account with new data.
+// The account is updated only if it has the expected version, when the expected version is set.
 func (a *AccountDB) UpdateAccount(account *Account) ledgerError.ApplicationError {
 	data, err := json.Marshal(account.Data)
 	if err != nil {
@@ -83,11 +138,43 @@ func (a *AccountDB) UpdateAccount(account *Account) ledgerError.ApplicationError
 		accountData = string(data)
 	}
 
-	q := "UPDATE accounts SET data = $1 WHERE id = $2"
-	_, err = a.db.Exec(q, accountData, account.ID)
+	tx, err := a.db.Begin()
 	if err != nil {
 		return DBError(err)
 	}
+	defer tx.Rollback()
 
+	// Lock the account to record the data it had before the update
+	var before string
+	var current int64
+	err = tx.QueryRow("SELECT data, version FROM accounts WHERE id = $1 FOR UPDATE", account.ID).Scan(&before, &current)
+	switch {
+	case err == sql.ErrNoRows:
+		return AccountNotFoundError(account.ID)
+	case err != nil:
+		return DBError(err)
+	}
+	var after string
+	q := "UPDATE accounts SET data = $1, version = version + 1 WHERE id = $2 AND ($3::bigint = 0 OR version = $3::bigint) RETURNING data"
+	err = tx.QueryRow(q, accountData, account.ID, account.ExpectedVersion).Scan(&after)
+	switch {
+	case err == sql.ErrNoRows:
+		return VersionMismatchError(account.ID, account.ExpectedVersion, current)
+	case err != nil:
+		return DBError(err)
+	}
+	err = recordVersion(tx, AuditEntityAccount, account.ID)
+	if err != nil {
+		return DBError(err)
+	}
+	err = writeAudit(tx, a.audit, AuditEntityAccount, account.ID, AuditActionUpdate, before, after)
+	if err != nil {
+		return DBError(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return DBError(err)
+	}
 	return nil
 }
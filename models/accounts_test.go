@@ -38,6 +38,15 @@ func (as *AccountsSuite) TestAccountsInfoAPI() {
 	assert.Equal(t, account.Balance, 0, "Invalid account balance")
 }
 
+func (as *AccountsSuite) TestGetResultByID() {
+	t := as.T()
+
+	accountsDB := NewAccountDB(as.db)
+	account, err := accountsDB.GetResultByID("100", "")
+	assert.Nil(t, account, "Account should not exist")
+	assert.Equal(t, "account.notfound", err.ErrorCode(), "Invalid error code")
+}
+
 func TestAccountsSuite(t *testing.T) {
 	suite.Run(t, new(AccountsSuite))
 }
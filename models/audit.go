@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+const (
+	// AuditEntityAccount is the entity type of the account changes in the audit log
+	AuditEntityAccount = "account"
+	// AuditEntityTransaction is the entity type of the transaction changes in the audit log
+	AuditEntityTransaction = "transaction"
+	// AuditActionCreate is the action of creating an account or transaction
+	AuditActionCreate = "create"
+	// AuditActionUpdate is the action of updating the data of an account or transaction
+	AuditActionUpdate = "update"
+)
+
+// AuditInfo identifies the caller and the request making the changes recorded in the audit log
+type AuditInfo struct {
+	Identity  string
+	RequestID string
+	Endpoint  string
+}
+
+// AuditEntry represents the response format of the audit log entries
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Timestamp  string          `json:"timestamp"`
+	Identity   string          `json:"identity"`
+	RequestID  string          `json:"request_id"`
+	Endpoint   string          `json:"endpoint"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+// auditSelectSQL selects the audit log entries in the format of `AuditEntry`.
+// The search terms and ranges apply on the data after the change, or before the change if there is no after.
+const auditSelectSQL = `SELECT id, timestamp, identity, request_id, endpoint, entity_type, entity_id, action, before, after
+			FROM (SELECT *, COALESCE(after, before) AS data FROM audit_log) AS audit_log`
+
+func scanAuditEntry(row rowScanner) (*AuditEntry, error) {
+	entry := &AuditEntry{}
+	var before, after []byte
+	err := row.Scan(&entry.ID, &entry.Timestamp, &entry.Identity, &entry.RequestID, &entry.Endpoint,
+		&entry.EntityType, &entry.EntityID, &entry.Action, &before, &after)
+	if err != nil {
+		return nil, err
+	}
+	// The missing data is returned as JSON null
+	entry.Before, entry.After = before, after
+	return entry, nil
+}
+
+// execer is implemented by both `sql.DB` and `sql.Tx`
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// writeAudit records the change of an entity in the audit log.
+// It is called with the DB transaction making the change, so that both are committed together.
+// The empty data before or after the change is stored as NULL.
+func writeAudit(tx execer, audit *AuditInfo, entityType, entityID, action, before, after string) error {
+	if audit == nil {
+		audit = &AuditInfo{}
+	}
+	_, err := tx.Exec(`INSERT INTO audit_log (identity, request_id, endpoint, entity_type, entity_id, action, before, after)
+				VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::jsonb, NULLIF($8, '')::jsonb)`,
+		audit.Identity, audit.RequestID, audit.Endpoint, entityType, entityID, action, before, after)
+	return err
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditSuite struct {
+	suite.Suite
+	db *sql.DB
+}
+
+func (as *AuditSuite) SetupTest() {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	assert.NotEmpty(as.T(), databaseURL)
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Panic("Unable to connect to Database:", err)
+	} else {
+		log.Println("Successfully established connection to database.")
+		as.db = db
+	}
+}
+
+func (as *AuditSuite) TestAuditAccountChanges() {
+	t := as.T()
+
+	audit := &AuditInfo{Identity: "alice", RequestID: "req-audit-001", Endpoint: "POST /v1/accounts"}
+	accountsDB := NewAccountDB(as.db).WithAudit(audit)
+	aerr := accountsDB.CreateAccount(&Account{ID: "audit-acc-1", Data: map[string]interface{}{"status": "active"}})
+	assert.Nil(t, aerr, "Error while creating account")
+	audit.Endpoint = "PUT /v1/accounts"
+	aerr = accountsDB.UpdateAccount(&Account{ID: "audit-acc-1", Data: map[string]interface{}{"status": "closed"}})
+	assert.Nil(t, aerr, "Error while updating account")
+
+	engine, aerr := NewSearchEngine(as.db, SearchNamespaceAudit)
+	assert.Nil(t, aerr, "Error creating new search engine")
+	results, aerr := engine.Query(`{"query": {"must": {"fields": [{"entity_id": {"eq": "audit-acc-1"}}]}}}`)
+	assert.Nil(t, aerr, "Error in building search query")
+	entries, _ := results.([]*AuditEntry)
+	assert.Equal(t, 2, len(entries), "Invalid number of audit entries")
+
+	assert.Equal(t, AuditActionCreate, entries[0].Action, "Invalid audit action")
+	assert.Equal(t, "POST /v1/accounts", entries[0].Endpoint, "Invalid audit endpoint")
+	assert.Equal(t, "null", string(entries[0].Before), "Invalid data before create")
+	assert.JSONEq(t, `{"status": "active"}`, string(entries[0].After), "Invalid data after create")
+
+	assert.Equal(t, AuditActionUpdate, entries[1].Action, "Invalid audit action")
+	assert.Equal(t, "alice", entries[1].Identity, "Invalid audit identity")
+	assert.Equal(t, "req-audit-001", entries[1].RequestID, "Invalid audit request ID")
+	assert.JSONEq(t, `{"status": "active"}`, string(entries[1].Before), "Invalid data before update")
+	assert.JSONEq(t, `{"status": "closed"}`, string(entries[1].After), "Invalid data after update")
+
+	// The terms apply on the data after the change
+	results, aerr = engine.Query(`{"query": {"must": {"terms": [{"status": "closed"}], "fields": [{"entity_id": {"eq": "audit-acc-1"}}]}}}`)
+	assert.Nil(t, aerr, "Error in building search query")
+	entries, _ = results.([]*AuditEntry)
+	assert.Equal(t, 1, len(entries), "Invalid number of audit entries")
+
+	// The audit log is append-only
+	_, err := as.db.Exec("DELETE FROM audit_log WHERE entity_id=$1", "audit-acc-1")
+	assert.NotNil(t, err, "Audit log entries should not be deleted")
+	_, err = as.db.Exec("UPDATE audit_log SET identity='mallory' WHERE entity_id=$1", "audit-acc-1")
+	assert.NotNil(t, err, "Audit log entries should not be updated")
+}
+
+func (as *AuditSuite) TestAuditTransactionChanges() {
+	t := as.T()
+
+	audit := &AuditInfo{Identity: "bob", RequestID: "req-audit-002", Endpoint: "POST /v1/transactions"}
+	transactionDB := NewTransactionDB(as.db).WithAudit(audit)
+	done := transactionDB.Transact(&Transaction{
+		ID:   "audit-txn-1",
+		Data: map[string]interface{}{"status": "pending"},
+		Lines: []*TransactionLine{
+			&TransactionLine{AccountID: "audit-acc-2", Delta: 100},
+			&TransactionLine{AccountID: "audit-acc-3", Delta: -100},
+		},
+	})
+	assert.Equal(t, true, done, "Transaction should be created")
+	aerr := transactionDB.UpdateTransaction(&Transaction{ID: "audit-txn-1", Data: map[string]interface{}{"status": "completed"}})
+	assert.Nil(t, aerr, "Error while updating transaction")
+
+	engine, aerr := NewSearchEngine(as.db, SearchNamespaceAudit)
+	assert.Nil(t, aerr, "Error creating new search engine")
+	results, aerr := engine.Query(`{"query": {"must": {"fields": [{"identity": {"eq": "bob"}}, {"request_id": {"eq": "req-audit-002"}}]}}}`)
+	assert.Nil(t, aerr, "Error in building search query")
+	entries, _ := results.([]*AuditEntry)
+	// The accounts created by the transaction lines are also recorded
+	assert.Equal(t, 4, len(entries), "Invalid number of audit entries")
+	last := entries[len(entries)-1]
+	assert.Equal(t, AuditEntityTransaction, last.EntityType, "Invalid audit entity type")
+	assert.Equal(t, AuditActionUpdate, last.Action, "Invalid audit action")
+	assert.JSONEq(t, `{"status": "pending"}`, string(last.Before), "Invalid data before update")
+
+	aerr = transactionDB.UpdateTransaction(&Transaction{ID: "audit-txn-missing"})
+	assert.Equal(t, "transaction.notfound", aerr.ErrorCode(), "Invalid error code")
+}
+
+func (as *AuditSuite) TearDownSuite() {
+	t := as.T()
+	_, err := as.db.Exec("DELETE FROM lines WHERE transaction_id = $1", "audit-txn-1")
+	if err != nil {
+		t.Fatal("Error deleting lines:", err)
+	}
+	_, err = as.db.Exec("DELETE FROM transactions WHERE id = $1", "audit-txn-1")
+	if err != nil {
+		t.Fatal("Error deleting transactions:", err)
+	}
+	_, err = as.db.Exec("DELETE FROM accounts WHERE id LIKE $1", "audit-acc-%")
+	if err != nil {
+		t.Fatal("Error deleting accounts:", err)
+	}
+}
+
+func TestAuditSuite(t *testing.T) {
+	suite.Run(t, new(AuditSuite))
+}
+
+func TestSearchAuditLog(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"size": 10, "sort_time": "desc"}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceAudit)
+	assert.Contains(t, sqlQuery.sql, "FROM audit_log) AS audit_log ORDER BY timestamp DESC, id DESC LIMIT 10", "Invalid SQL query")
+}
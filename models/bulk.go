@@ -0,0 +1,567 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/lib/pq"
+)
+
+const (
+	// BulkBatchSize is the default number of records imported in a single DB transaction
+	BulkBatchSize = 1000
+	// BulkMaxBytes is the default limit of the size of a bulk import request
+	BulkMaxBytes = 1 << 30
+	// BulkRecordAccount is the type of the account records
+	BulkRecordAccount = "account"
+	// BulkRecordTransaction is the type of the transaction records
+	BulkRecordTransaction = "transaction"
+	// BulkStatusFailed says that the record is not imported, as its batch failed
+	BulkStatusFailed = "failed"
+)
+
+// BulkRecord represents a line of the NDJSON bulk import and export,
+// which has either an account or a transaction. The trailer is the last line of an export.
+type BulkRecord struct {
+	Account     *Account       `json:"account,omitempty"`
+	Transaction *Transaction   `json:"transaction,omitempty"`
+	Trailer     *ExportTrailer `json:"trailer,omitempty"`
+}
+
+// BulkResult represents the result of importing a line of the NDJSON bulk import
+type BulkResult struct {
+	Line   int    `json:"line"`
+	Type   string `json:"type,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkImporter imports the NDJSON records of accounts and transactions in batches
+type BulkImporter struct {
+	db        *sql.DB
+	batchSize int
+	audit     *AuditInfo
+	schemas   *DataSchemas
+}
+
+// NewBulkImporter returns a new instance of `BulkImporter`
+func NewBulkImporter(db *sql.DB, batchSize int) *BulkImporter {
+	if batchSize <= 0 {
+		batchSize = BulkBatchSize
+	}
+	return &BulkImporter{db: db, batchSize: batchSize}
+}
+
+// WithAudit returns a copy of the `BulkImporter` which records the imported records in the audit log as made by the caller
+func (b *BulkImporter) WithAudit(audit *AuditInfo) *BulkImporter {
+	importer := *b
+	importer.audit = audit
+	return &importer
+}
+
+// WithSchemas returns a copy of the `BulkImporter` which validates the data of the records against the schemas
+func (b *BulkImporter) WithSchemas(schemas *DataSchemas) *BulkImporter {
+	importer := *b
+	importer.schemas = schemas
+	return &importer
+}
+
+// bulkItem holds a parsed record of the import along with its result
+type bulkItem struct {
+	record *BulkRecord
+	result *BulkResult
+}
+
+// Import reads the NDJSON records from the reader and imports them in batches.
+// The results of the records in each batch are reported once the batch is imported.
+// The records are read twice, as the links of the reversals are collected before importing the records.
+func (b *BulkImporter) Import(r io.ReadSeeker, report func([]*BulkResult) error) error {
+	links, err := b.scanReversals(r)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(r)
+	var items []*bulkItem
+	flush := func() error {
+		results := b.importBatch(items)
+		items = nil
+		return report(results)
+	}
+
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			// The records read before the error are reported as failed without importing them
+			results := make([]*BulkResult, 0, len(items))
+			for _, item := range items {
+				if item.result.Status == "" {
+					item.result.Status = BulkStatusFailed
+					item.result.Error = err.Error()
+				}
+				results = append(results, item.result)
+			}
+			if len(results) != 0 {
+				report(results)
+			}
+			return err
+		}
+		if item := b.parseRecord(line, raw); item != nil {
+			validateReversalLinks(item, links)
+			items = append(items, item)
+			if len(items) == b.batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(items) != 0 {
+		return flush()
+	}
+	return nil
+}
+
+// scanReversals returns the keys linking the reversals in the data of the valid transactions read from the reader
+func (b *BulkImporter) scanReversals(r io.Reader) (map[string]map[string]string, error) {
+	links := make(map[string]map[string]string)
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if item := b.parseRecord(line, raw); item != nil && item.record != nil && item.record.Transaction != nil {
+			txn := item.record.Transaction
+			for _, key := range reversalKeys {
+				if id, ok := txn.Data[key].(string); ok {
+					if links[txn.ID] == nil {
+						links[txn.ID] = make(map[string]string)
+					}
+					links[txn.ID][key] = id
+				}
+			}
+		}
+		if err == io.EOF {
+			return links, nil
+		}
+	}
+}
+
+// reversalBackKeys are the keys linking back the transactions linked by the reversal keys
+var reversalBackKeys = map[string]string{
+	"reverses":    "reversed_by",
+	"reversed_by": "reverses",
+}
+
+// validateReversalLinks invalidates the transaction having the keys of the reversals
+// which don't point at the transactions of the same import linked back to it
+func validateReversalLinks(item *bulkItem, links map[string]map[string]string) {
+	if item.record == nil || item.record.Transaction == nil {
+		return
+	}
+	txn := item.record.Transaction
+	for _, key := range reversalKeys {
+		value, ok := txn.Data[key]
+		if !ok {
+			continue
+		}
+		id, _ := value.(string)
+		if id == "" || id == txn.ID || links[id][reversalBackKeys[key]] != txn.ID {
+			item.record = nil
+			item.result.Status = BatchStatusInvalid
+			item.result.Error = fmt.Sprintf("Key %v should point at a transaction of the import linked back by %v", key, reversalBackKeys[key])
+			return
+		}
+	}
+}
+
+// parseRecord parses and validates a line of the import along with the schema of its data
+func (b *BulkImporter) parseRecord(line int, raw []byte) *bulkItem {
+	item := parseBulkRecord(line, raw)
+	if item != nil {
+		b.validateSchema(item)
+	}
+	return item
+}
+
+// parseBulkRecord parses and validates a line of the import.
+// The empty lines and the trailer of an export are skipped.
+func parseBulkRecord(line int, raw []byte) *bulkItem {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+	item := &bulkItem{record: &BulkRecord{}, result: &BulkResult{Line: line}}
+	invalid := func(err error) *bulkItem {
+		item.record = nil
+		item.result.Status = BatchStatusInvalid
+		item.result.Error = err.Error()
+		return item
+	}
+
+	if err := json.Unmarshal(raw, item.record); err != nil {
+		return invalid(err)
+	}
+	account, txn := item.record.Account, item.record.Transaction
+	switch {
+	case item.record.Trailer != nil && account == nil && txn == nil:
+		return nil
+	case account != nil && txn == nil:
+		item.result.Type = BulkRecordAccount
+		item.result.ID = account.ID
+		if account.ID == "" {
+			return invalid(errors.New("Missing account id"))
+		}
+		if err := ValidateData(account.Data); err != nil {
+			return invalid(err)
+		}
+	case txn != nil && account == nil:
+		item.result.Type = BulkRecordTransaction
+		item.result.ID = txn.ID
+		if txn.ID == "" {
+			return invalid(errors.New("Missing transaction id"))
+		}
+		if err := ValidateData(txn.Data); err != nil {
+			return invalid(err)
+		}
+		if err := ValidateTimestamp(txn.Timestamp); err != nil {
+			return invalid(err)
+		}
+		if !txn.IsValid() {
+			return invalid(errors.New("Transaction lines should have a total delta of zero"))
+		}
+	default:
+		return invalid(errors.New("Record should have either an account or a transaction"))
+	}
+	return item
+}
+
+// validateSchema invalidates the record having the data which violates its schema
+func (b *BulkImporter) validateSchema(item *bulkItem) {
+	if item.record == nil {
+		return
+	}
+	var aerr ledgerError.ApplicationError
+	if item.record.Account != nil {
+		aerr = b.schemas.Validate(AuditEntityAccount, item.record.Account.Data)
+	} else {
+		aerr = b.schemas.Validate(AuditEntityTransaction, item.record.Transaction.Data)
+	}
+	if aerr != nil {
+		item.record = nil
+		item.result.Status = BatchStatusInvalid
+		item.result.Error = aerr.Error()
+	}
+}
+
+// importBatch imports the valid records of a batch in a single DB transaction and returns the results of all the records.
+// The accounts of a batch are imported before its transactions, following the semantics of the API:
+// the existing accounts are conflicts and the existing transactions are either duplicates or conflicts.
+// When the batch violates an unique constraint, as some of its records are created by the other requests meanwhile,
+// the records of the batch are imported one at a time, so that only those records are reported as conflicts.
+func (b *BulkImporter) importBatch(items []*bulkItem) []*BulkResult {
+	results := make([]*BulkResult, 0, len(items))
+	var accounts, transactions []*bulkItem
+	for _, item := range items {
+		results = append(results, item.result)
+		if item.record == nil {
+			continue
+		}
+		if item.record.Account != nil {
+			accounts = append(accounts, item)
+		} else {
+			transactions = append(transactions, item)
+		}
+	}
+
+	err := b.importRecords(accounts, transactions)
+	if !isUniqueViolation(err) {
+		failRecords(append(accounts, transactions...), err)
+		return results
+	}
+	log.Println("Retrying batch one record at a time:", err)
+	for _, item := range accounts {
+		item.result.Status = ""
+		failRecords([]*bulkItem{item}, b.importRecords([]*bulkItem{item}, nil))
+	}
+	for _, item := range transactions {
+		item.result.Status = ""
+		failRecords([]*bulkItem{item}, b.importRecords(nil, []*bulkItem{item}))
+	}
+	return results
+}
+
+// failRecords reports the error in the results of the records which would have been created otherwise.
+// The records violating an unique constraint are conflicts with the records created meanwhile.
+func failRecords(items []*bulkItem, err error) {
+	if err == nil {
+		return
+	}
+	log.Println("Error importing batch:", err)
+	status := BulkStatusFailed
+	if len(items) == 1 && isUniqueViolation(err) {
+		status = BatchStatusConflict
+	}
+	for _, item := range items {
+		if item.result.Status == BatchStatusCreated {
+			item.result.Status = status
+			item.result.Error = err.Error()
+		}
+	}
+}
+
+func (b *BulkImporter) importRecords(accounts []*bulkItem, transactions []*bulkItem) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(accounts) != 0 {
+		if err := importAccounts(tx, accounts, b.audit); err != nil {
+			return err
+		}
+	}
+	if len(transactions) != 0 {
+		if err := importTransactions(tx, transactions, b.audit); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// importAccounts copies the accounts which don't exist already
+func importAccounts(tx *sql.Tx, accounts []*bulkItem, audit *AuditInfo) error {
+	var ids []string
+	for _, item := range accounts {
+		ids = append(ids, item.record.Account.ID)
+	}
+	existing := make(map[string]bool)
+	rows, err := tx.Query("SELECT id FROM accounts WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		existing[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	stmt, err := tx.Prepare(pq.CopyIn("accounts", "id", "data"))
+	if err != nil {
+		return err
+	}
+	var created []string
+	for _, item := range accounts {
+		account := item.record.Account
+		if existing[account.ID] {
+			item.result.Status = BatchStatusConflict
+			continue
+		}
+		existing[account.ID] = true
+		data, err := marshalData(account.Data)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(account.ID, data); err != nil {
+			return err
+		}
+		item.result.Status = BatchStatusCreated
+		created = append(created, account.ID)
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	return auditImport(tx, audit, AuditEntityAccount, created)
+}
+
+// importTransactions copies the transactions which don't exist already along with their lines
+func importTransactions(tx *sql.Tx, transactions []*bulkItem, audit *AuditInfo) error {
+	var ids []string
+	for _, item := range transactions {
+		ids = append(ids, item.record.Transaction.ID)
+	}
+	existing := make(map[string][]*TransactionLine)
+	rows, err := tx.Query(`SELECT transactions.id, lines.account_id, lines.delta FROM transactions
+				LEFT OUTER JOIN lines ON lines.transaction_id = transactions.id
+				WHERE transactions.id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var accountID sql.NullString
+		var delta sql.NullInt64
+		if err := rows.Scan(&id, &accountID, &delta); err != nil {
+			return err
+		}
+		lines := existing[id]
+		if accountID.Valid {
+			lines = append(lines, &TransactionLine{AccountID: accountID.String, Delta: int(delta.Int64)})
+		}
+		existing[id] = lines
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// The exactly duplicate transactions are ignored
+	var newTransactions []*Transaction
+	var transactionIDs, accountIDs []string
+	for _, item := range transactions {
+		txn := item.record.Transaction
+		if lines, ok := existing[txn.ID]; ok {
+			if containsSameElements(txn.Lines, lines) {
+				item.result.Status = BatchStatusDuplicate
+			} else {
+				item.result.Status = BatchStatusConflict
+			}
+			continue
+		}
+		existing[txn.ID] = txn.Lines
+		newTransactions = append(newTransactions, txn)
+		transactionIDs = append(transactionIDs, txn.ID)
+		for _, line := range txn.Lines {
+			accountIDs = append(accountIDs, line.AccountID)
+		}
+		item.result.Status = BatchStatusCreated
+	}
+	if len(newTransactions) == 0 {
+		return nil
+	}
+
+	// Accounts do not need to be predefined
+	// they are called into existence when they are first used.
+	rows, err = tx.Query("INSERT INTO accounts (id) SELECT DISTINCT unnest($1::varchar[]) ON CONFLICT (id) DO NOTHING RETURNING id", pq.Array(accountIDs))
+	if err != nil {
+		return err
+	}
+	var createdAccounts []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		createdAccounts = append(createdAccounts, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if err := auditImport(tx, audit, AuditEntityAccount, createdAccounts); err != nil {
+		return err
+	}
+
+	timestamp := time.Now().UTC().Format(LedgerTimestampLayout)
+	stmt, err := tx.Prepare(pq.CopyIn("transactions", "id", "timestamp", "data"))
+	if err != nil {
+		return err
+	}
+	for _, txn := range newTransactions {
+		if txn.Timestamp == "" {
+			txn.Timestamp = timestamp
+		}
+		data, err := marshalData(txn.Data)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(txn.ID, txn.Timestamp, data); err != nil {
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	stmt, err = tx.Prepare(pq.CopyIn("lines", "transaction_id", "account_id", "delta"))
+	if err != nil {
+		return err
+	}
+	for _, txn := range newTransactions {
+		for _, line := range txn.Lines {
+			if _, err := stmt.Exec(txn.ID, line.AccountID, line.Delta); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	// The reversals are recorded once both the transactions linked to each other exist
+	_, err = tx.Exec(`INSERT INTO reversals (transaction_id, reversal_id)
+				SELECT original.id, reversal.id FROM transactions AS original
+				JOIN transactions AS reversal ON reversal.id = original.data->>'reversed_by' AND reversal.data->>'reverses' = original.id
+				WHERE original.id = ANY($1) OR reversal.id = ANY($1)`, pq.Array(transactionIDs))
+	if err != nil {
+		return err
+	}
+	return auditImport(tx, audit, AuditEntityTransaction, transactionIDs)
+}
+
+// auditImport records the creation of the imported accounts or transactions
+// in their version history and the audit log
+func auditImport(tx *sql.Tx, audit *AuditInfo, entityType string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(versionsSQL[entityType].insertAll, pq.Array(ids)); err != nil {
+		return err
+	}
+	if audit == nil {
+		audit = &AuditInfo{}
+	}
+	table := "accounts"
+	if entityType == AuditEntityTransaction {
+		table = "transactions"
+	}
+	_, err := tx.Exec(`INSERT INTO audit_log (identity, request_id, endpoint, entity_type, entity_id, action, after)
+				SELECT $1, $2, $3, $4, id, $5, data FROM `+table+` WHERE id = ANY($6)`,
+		audit.Identity, audit.RequestID, audit.Endpoint, entityType, AuditActionCreate, pq.Array(ids))
+	return err
+}
+
+// marshalData converts the data of accounts and transactions to JSON
+func marshalData(data map[string]interface{}) (string, error) {
+	if data == nil {
+		return "{}", nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
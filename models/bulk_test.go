@@ -0,0 +1,318 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestParseBulkRecord(t *testing.T) {
+	item := parseBulkRecord(1, []byte(`{"account": {"id": "alice", "data": {"product": "qw"}}}`))
+	assert.Equal(t, BulkRecordAccount, item.result.Type, "Invalid record type")
+	assert.Equal(t, "alice", item.result.ID, "Invalid record ID")
+	assert.Equal(t, "", item.result.Status, "Valid record should not have status")
+
+	item = parseBulkRecord(2, []byte(`{"transaction": {"id": "t001", "lines": [{"account": "alice", "delta": 100}, {"account": "bob", "delta": -100}]}}`))
+	assert.Equal(t, BulkRecordTransaction, item.result.Type, "Invalid record type")
+	assert.Equal(t, "t001", item.result.ID, "Invalid record ID")
+	assert.Equal(t, "", item.result.Status, "Valid record should not have status")
+
+	item = parseBulkRecord(3, []byte(`{"trailer": {"accounts": 2, "transactions": 1}}`))
+	assert.Nil(t, item, "Trailer record should be skipped")
+
+	invalidRecords := []string{
+		`INVALID`,
+		`{}`,
+		`{"account": {"id": "alice"}, "transaction": {"id": "t001"}}`,
+		`{"account": {"data": {"product": "qw"}}}`,
+		`{"account": {"id": "alice", "data": {"product.id": "qw"}}}`,
+		`{"transaction": {"id": "t001", "timestamp": "2017-01-01"}}`,
+		`{"transaction": {"id": "t001", "lines": [{"account": "alice", "delta": 100}]}}`,
+	}
+	for i, record := range invalidRecords {
+		item = parseBulkRecord(i, []byte(record))
+		assert.Equal(t, BatchStatusInvalid, item.result.Status, "Record should be invalid: "+record)
+		assert.NotEmpty(t, item.result.Error, "Invalid record should have error")
+	}
+}
+
+func TestImportReadError(t *testing.T) {
+	records := `{"account": {"id": "alice"}}
+INVALID
+{"account": {"id": "bob"`
+	reader := &rereadErrReader{records: records, err: errors.New("request too large")}
+	var results []*BulkResult
+	importer := NewBulkImporter(nil, 10)
+	err := importer.Import(reader, func(batch []*BulkResult) error {
+		results = append(results, batch...)
+		return nil
+	})
+	assert.NotNil(t, err, "Read error should be returned")
+	assert.Equal(t, 2, len(results), "Results count doesn't match")
+	assert.Equal(t, BulkStatusFailed, results[0].Status, "Record read before the error should fail")
+	assert.Equal(t, "request too large", results[0].Error, "Result error doesn't match")
+	assert.Equal(t, BatchStatusInvalid, results[1].Status, "Invalid record should stay invalid")
+}
+
+// errReader always fails with the given error
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// rereadErrReader reads the records, but fails with the given error after the records once they are read again
+type rereadErrReader struct {
+	records string
+	err     error
+	reader  io.Reader
+}
+
+func (r *rereadErrReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		r.reader = strings.NewReader(r.records)
+	}
+	return r.reader.Read(p)
+}
+
+func (r *rereadErrReader) Seek(offset int64, whence int) (int64, error) {
+	r.reader = io.MultiReader(strings.NewReader(r.records), errReader{r.err})
+	return 0, nil
+}
+
+func TestImportScanError(t *testing.T) {
+	reader := struct {
+		io.Reader
+		io.Seeker
+	}{errReader{errors.New("request too large")}, strings.NewReader("")}
+	var results []*BulkResult
+	err := NewBulkImporter(nil, 10).Import(reader, func(batch []*BulkResult) error {
+		results = append(results, batch...)
+		return nil
+	})
+	assert.NotNil(t, err, "Read error should be returned")
+	assert.Equal(t, 0, len(results), "No records should be reported")
+}
+
+func TestValidateReversalLinks(t *testing.T) {
+	records := `{"transaction": {"id": "t1", "data": {"reversed_by": "t2"}, "lines": [{"account": "alice", "delta": 100}, {"account": "bob", "delta": -100}]}}
+{"transaction": {"id": "t2", "data": {"reverses": "t1"}, "lines": [{"account": "alice", "delta": -100}, {"account": "bob", "delta": 100}]}}
+{"transaction": {"id": "t3", "data": {"reverses": "t1"}, "lines": [{"account": "alice", "delta": -100}, {"account": "bob", "delta": 100}]}}
+{"transaction": {"id": "t4", "data": {"reversed_by": "t5"}, "lines": [{"account": "alice", "delta": 100}, {"account": "bob", "delta": -100}]}}
+{"transaction": {"id": "t5", "data": {"reverses": 4}, "lines": [{"account": "alice", "delta": -100}, {"account": "bob", "delta": 100}]}}
+{"transaction": {"id": "t6", "data": {"reversed_by": "t6", "reverses": "t6"}, "lines": []}}`
+	importer := NewBulkImporter(nil, 10)
+	links, err := importer.scanReversals(strings.NewReader(records))
+	assert.Equal(t, nil, err, "Error while scanning reversals")
+
+	valid := []bool{true, true, false, false, false, false}
+	for i, record := range strings.Split(records, "\n") {
+		item := importer.parseRecord(i+1, []byte(record))
+		validateReversalLinks(item, links)
+		if valid[i] {
+			assert.Equal(t, "", item.result.Status, "Record should be valid: "+record)
+		} else {
+			assert.Equal(t, BatchStatusInvalid, item.result.Status, "Record should be invalid: "+record)
+		}
+	}
+}
+
+type BulkSuite struct {
+	suite.Suite
+	db *sql.DB
+}
+
+func (bs *BulkSuite) SetupSuite() {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	assert.NotEmpty(bs.T(), databaseURL)
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Panic("Unable to connect to Database:", err)
+	} else {
+		log.Println("Successfully established connection to database.")
+		bs.db = db
+	}
+}
+
+func (bs *BulkSuite) TestImport() {
+	t := bs.T()
+
+	records := `{"account": {"id": "bulk1", "data": {"product": "qw"}}}
+{"transaction": {"id": "bulk_t001", "lines": [{"account": "bulk1", "delta": 100}, {"account": "bulk2", "delta": -100}]}}
+
+{"transaction": {"id": "bulk_t002", "timestamp": "2017-01-01 13:01:05.000", "lines": [{"account": "bulk1", "delta": 50}, {"account": "bulk2", "delta": -50}]}}
+{"transaction": {"id": "bulk_t001", "lines": [{"account": "bulk1", "delta": 100}, {"account": "bulk2", "delta": -100}]}}
+{"transaction": {"id": "bulk_t002", "lines": [{"account": "bulk1", "delta": 10}, {"account": "bulk2", "delta": -10}]}}
+{"account": {"id": "bulk1"}}
+INVALID
+`
+	var results []*BulkResult
+	importer := NewBulkImporter(bs.db, 3)
+	err := importer.Import(strings.NewReader(records), func(batch []*BulkResult) error {
+		results = append(results, batch...)
+		return nil
+	})
+	assert.Equal(t, nil, err, "Error while importing records")
+	assert.Equal(t, 7, len(results), "Results count doesn't match")
+
+	expected := []struct {
+		line   int
+		status string
+	}{
+		{1, BatchStatusCreated},
+		{2, BatchStatusCreated},
+		{4, BatchStatusCreated},
+		{5, BatchStatusDuplicate},
+		{6, BatchStatusConflict},
+		{7, BatchStatusConflict},
+		{8, BatchStatusInvalid},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.line, results[i].Line, "Result line doesn't match")
+		assert.Equal(t, e.status, results[i].Status, "Result status doesn't match")
+	}
+
+	accountDB := NewAccountDB(bs.db)
+	account, aerr := accountDB.GetByID("bulk2")
+	assert.Equal(t, nil, aerr, "Error while getting account")
+	assert.Equal(t, -150, account.Balance, "Account balance doesn't match")
+}
+
+func (bs *BulkSuite) TestExport() {
+	t := bs.T()
+
+	transactionDB := NewTransactionDB(bs.db)
+	done := transactionDB.Transact(&Transaction{
+		ID: "export_t001",
+		Lines: []*TransactionLine{
+			&TransactionLine{
+				AccountID: "export1",
+				Delta:     100,
+			},
+			&TransactionLine{
+				AccountID: "export2",
+				Delta:     -100,
+			},
+		},
+		Data: map[string]interface{}{
+			"action": "export",
+		},
+	})
+	assert.Equal(t, true, done, "Transaction should be created")
+
+	var export bytes.Buffer
+	exporter := NewExporter(bs.db)
+	err := exporter.Export(&export)
+	assert.Equal(t, nil, err, "Error while exporting ledger")
+
+	lines := strings.SplitAfter(strings.TrimSuffix(export.String(), "\n"), "\n")
+	records := lines[:len(lines)-1]
+	var trailerRecord BulkRecord
+	err = json.Unmarshal([]byte(lines[len(lines)-1]), &trailerRecord)
+	assert.Equal(t, nil, err, "Error while parsing trailer")
+	trailer := trailerRecord.Trailer
+	assert.NotNil(t, trailer, "Export should end with trailer")
+	assert.Equal(t, len(records), trailer.Accounts+trailer.Transactions, "Records count doesn't match")
+	assert.Equal(t, 0, trailer.BalanceSum, "Balance sum should be zero")
+	hash := sha256.Sum256([]byte(strings.Join(records, "")))
+	assert.Equal(t, hex.EncodeToString(hash[:]), trailer.SHA256, "Export hash doesn't match")
+	assert.Contains(t, export.String(), `{"transaction":{"id":"export_t001",`, "Transaction should be exported")
+}
+
+func (bs *BulkSuite) TestReimportReversals() {
+	t := bs.T()
+
+	transactionDB := NewTransactionDB(bs.db)
+	done := transactionDB.Transact(&Transaction{
+		ID: "reimport_t001",
+		Lines: []*TransactionLine{
+			&TransactionLine{
+				AccountID: "reimport1",
+				Delta:     100,
+			},
+			&TransactionLine{
+				AccountID: "reimport2",
+				Delta:     -100,
+			},
+		},
+	})
+	assert.Equal(t, true, done, "Transaction should be created")
+	aerr := transactionDB.Reverse("reimport_t001", &Transaction{ID: "reimport_t001_reversal"})
+	assert.Equal(t, nil, aerr, "Error while reversing transaction")
+
+	export := func() (string, *ExportTrailer) {
+		var export bytes.Buffer
+		err := NewExporter(bs.db).Export(&export)
+		assert.Equal(t, nil, err, "Error while exporting ledger")
+		lines := strings.Split(strings.TrimSuffix(export.String(), "\n"), "\n")
+		var trailerRecord BulkRecord
+		err = json.Unmarshal([]byte(lines[len(lines)-1]), &trailerRecord)
+		assert.Equal(t, nil, err, "Error while parsing trailer")
+		trailerRecord.Trailer.SHA256 = ""
+		return export.String(), trailerRecord.Trailer
+	}
+	records, trailer := export()
+
+	// The exported ledger is imported into the empty ledger
+	for _, table := range []string{"lines", "transactions", "accounts"} {
+		_, err := bs.db.Exec("DELETE FROM " + table)
+		assert.Equal(t, nil, err, "Error deleting "+table)
+	}
+	var results []*BulkResult
+	err := NewBulkImporter(bs.db, 2).Import(strings.NewReader(records), func(batch []*BulkResult) error {
+		results = append(results, batch...)
+		return nil
+	})
+	assert.Equal(t, nil, err, "Error while importing records")
+	assert.Equal(t, trailer.Accounts+trailer.Transactions, len(results), "Results count doesn't match")
+	for _, result := range results {
+		assert.Equal(t, BatchStatusCreated, result.Status, "Record should be created: "+result.ID+" "+result.Error)
+	}
+	_, reimportTrailer := export()
+	assert.Equal(t, trailer, reimportTrailer, "Export of the imported ledger doesn't match")
+
+	var reversalID string
+	err = bs.db.QueryRow("SELECT reversal_id FROM reversals WHERE transaction_id = $1", "reimport_t001").Scan(&reversalID)
+	assert.Equal(t, nil, err, "Reversal should be imported")
+	assert.Equal(t, "reimport_t001_reversal", reversalID, "Reversal doesn't match")
+	aerr = transactionDB.Reverse("reimport_t001", &Transaction{ID: "reimport_t001_reversal_2"})
+	if assert.NotNil(t, aerr, "Imported reversal should prevent another reversal") {
+		assert.Equal(t, "transaction.reversed", aerr.ErrorCode(), "Error code doesn't match")
+	}
+}
+
+func (bs *BulkSuite) TearDownSuite() {
+	log.Println("Cleaning up the test database")
+
+	t := bs.T()
+	_, err := bs.db.Exec(`DELETE FROM lines`)
+	if err != nil {
+		t.Fatal("Error deleting lines:", err)
+	}
+	_, err = bs.db.Exec(`DELETE FROM transactions`)
+	if err != nil {
+		t.Fatal("Error deleting transactions:", err)
+	}
+	_, err = bs.db.Exec(`DELETE FROM accounts`)
+	if err != nil {
+		t.Fatal("Error deleting accounts:", err)
+	}
+}
+
+func TestBulkSuite(t *testing.T) {
+	suite.Run(t, new(BulkSuite))
+}
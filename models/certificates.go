@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+// ClientCertificate represents the identity of the TLS client certificates with a subject or SAN,
+// with its scopes and the accounts it is restricted to
+type ClientCertificate struct {
+	Name     string          `json:"name"`
+	Scopes   []string        `json:"scopes"`
+	Accounts AccountPatterns `json:"accounts"`
+}
+
+// ClientCertificateStore finds the identities of the client certificates
+type ClientCertificateStore interface {
+	GetByName(name string) (*ClientCertificate, ledgerError.ApplicationError)
+}
+
+// ClientCertificateFile provides the identities of the client certificates loaded from a JSON file
+type ClientCertificateFile struct {
+	certificates map[string]*ClientCertificate
+}
+
+// LoadClientCertificateFile loads the identities of the client certificates from the JSON file
+// having a list of `ClientCertificate`
+func LoadClientCertificateFile(path string) (*ClientCertificateFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certificates []*ClientCertificate
+	if err := json.Unmarshal(data, &certificates); err != nil {
+		return nil, err
+	}
+
+	file := &ClientCertificateFile{certificates: make(map[string]*ClientCertificate, len(certificates))}
+	for _, certificate := range certificates {
+		if certificate.Name == "" {
+			return nil, fmt.Errorf("Client certificate should have a name")
+		}
+		if err := ValidateScopes(certificate.Scopes); err != nil {
+			return nil, err
+		}
+		file.certificates[certificate.Name] = certificate
+	}
+	return file, nil
+}
+
+// GetByName returns the identity of the client certificate with the subject or SAN,
+// or nil if the identity doesn't exist
+func (f *ClientCertificateFile) GetByName(name string) (*ClientCertificate, ledgerError.ApplicationError) {
+	return f.certificates[name], nil
+}
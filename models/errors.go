@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/RealImage/QLedger/errors"
 )
 
@@ -35,3 +38,255 @@ func JSONError(err error) errors.ApplicationError {
 		Message: "JSON Error: " + err.Error(),
 	}
 }
+
+// AccountNotFoundError returns account not found error type
+func AccountNotFoundError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "account.notfound",
+		Message: "Account not found: " + id,
+	}
+}
+
+// TransactionNotFoundError returns transaction not found error type
+func TransactionNotFoundError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "transaction.notfound",
+		Message: "Transaction not found: " + id,
+	}
+}
+
+// TransactionConflictError returns conflicting transaction error type
+func TransactionConflictError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "transaction.conflict",
+		Message: "Transaction is conflicting with an existing transaction: " + id,
+	}
+}
+
+// TransactionReversedError returns already reversed transaction error type
+func TransactionReversedError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "transaction.reversed",
+		Message: "Transaction is already reversed: " + id,
+	}
+}
+
+// PayloadInvalidError returns invalid request payload error type
+func PayloadInvalidError(err error) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "payload.invalid",
+		Message: "Invalid payload: " + err.Error(),
+	}
+}
+
+// DataKeyInvalidError returns invalid data key error type
+func DataKeyInvalidError(key string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "data.key.invalid",
+		Message: "Invalid key in data json: " + key,
+		Details: map[string]interface{}{"key": key},
+	}
+}
+
+// DataKeyReservedError returns the error type of the keys of the data which are set only by the ledger
+func DataKeyReservedError(key string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "data.key.reserved",
+		Message: "Reserved key in data json: " + key,
+		Details: map[string]interface{}{"key": key},
+	}
+}
+
+// TimestampInvalidError returns invalid timestamp error type
+func TimestampInvalidError(timestamp string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "timestamp.invalid",
+		Message: "Invalid timestamp: " + timestamp,
+		Details: map[string]interface{}{"timestamp": timestamp, "layout": LedgerTimestampLayout},
+	}
+}
+
+// AccountConflictError returns conflicting account error type
+func AccountConflictError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "account.conflict",
+		Message: "Account already exists: " + id,
+	}
+}
+
+// TransactionInvalidError returns invalid transaction error type
+func TransactionInvalidError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "transaction.invalid",
+		Message: "Transaction lines don't sum to zero: " + id,
+	}
+}
+
+// ReversalInvalidError returns invalid reversal transaction error type
+func ReversalInvalidError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "reversal.invalid",
+		Message: "Reversal should have an ID and no lines: " + id,
+	}
+}
+
+// BatchInvalidError returns invalid transaction batch error type with the results of the batch
+func BatchInvalidError(results []*BatchItemResult) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "batch.invalid",
+		Message: "Transaction batch has invalid transactions",
+		Details: results,
+	}
+}
+
+// BatchConflictError returns conflicting transaction batch error type with the results of the batch
+func BatchConflictError(results []*BatchItemResult) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "batch.conflict",
+		Message: "Transaction batch has conflicting transactions",
+		Details: results,
+	}
+}
+
+// InternalError returns internal error type
+func InternalError(err error) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "internal.error",
+		Message: "Internal Error: " + err.Error(),
+	}
+}
+
+// TransactionFailedError returns failed transaction error type
+func TransactionFailedError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "transaction.failed",
+		Message: "Transaction failed: " + id,
+	}
+}
+
+// TokenInvalidError returns invalid API token error type
+func TokenInvalidError(err error) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "token.invalid",
+		Message: "Invalid API token: " + err.Error(),
+	}
+}
+
+// AccountForbiddenError returns forbidden account error type
+func AccountForbiddenError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "account.forbidden",
+		Message: "Access to the account is not allowed: " + id,
+		Details: map[string]interface{}{"account": id},
+	}
+}
+
+// AccountsRestrictedError returns the error type of the operations not allowed
+// for the callers restricted to some accounts
+func AccountsRestrictedError() errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "account.forbidden",
+		Message: "Operation is not allowed when restricted to accounts",
+	}
+}
+
+// VersionInvalidError returns invalid data version error type
+func VersionInvalidError(version string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "version.invalid",
+		Message: "Invalid version: " + version,
+		Details: map[string]interface{}{"version": version},
+	}
+}
+
+// VersionNotFoundError returns data version not found error type
+func VersionNotFoundError(id string, version int64) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "version.notfound",
+		Message: fmt.Sprintf("Version not found: %v (%v)", id, version),
+		Details: map[string]interface{}{"id": id, "version": version},
+	}
+}
+
+// VersionMismatchError returns the error type of the updates expecting a version other than the current version
+func VersionMismatchError(id string, expected int64, current int64) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "version.mismatch",
+		Message: fmt.Sprintf("Version mismatch: %v (expected %v, current %v)", id, expected, current),
+		Details: map[string]interface{}{"id": id, "expected_version": expected, "current_version": current},
+	}
+}
+
+// PatchInvalidError returns invalid patch error type
+func PatchInvalidError(err error) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "patch.invalid",
+		Message: "Invalid patch: " + err.Error(),
+	}
+}
+
+// PatchTypeInvalidError returns the error type of the patches with unsupported content type
+func PatchTypeInvalidError(contentType string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "patch.type.invalid",
+		Message: "Unsupported patch content type: " + contentType,
+		Details: map[string]interface{}{"content_type": contentType},
+	}
+}
+
+// PatchConflictError returns the error type of the patches which can't be applied on the current data
+func PatchConflictError(id string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "patch.conflict",
+		Message: "Patch can't be applied on the data: " + id,
+	}
+}
+
+// DataKeyFrozenError returns the error type of the updates changing the frozen keys of the data
+func DataKeyFrozenError(id string, keys []string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "data.key.frozen",
+		Message: fmt.Sprintf("Frozen keys can't be changed: %v (%v)", id, strings.Join(keys, ", ")),
+		Details: map[string]interface{}{"id": id, "keys": keys},
+	}
+}
+
+// DataSchemaInvalidError returns the error type of the data violating its JSON Schema
+func DataSchemaInvalidError(schema string, violations []*SchemaViolation) errors.ApplicationError {
+	violation := violations[0].Message
+	if violations[0].Path != "" {
+		violation = violations[0].Path + ": " + violation
+	}
+	return &errors.BaseApplicationError{
+		Code:    "data.schema.invalid",
+		Message: fmt.Sprintf("Data doesn't match the schema: %v (%v)", schema, violation),
+		Details: map[string]interface{}{"schema": schema, "violations": violations},
+	}
+}
+
+// SearchFieldInvalidError returns the error type of the fields which can't be searched in the namespace
+func SearchFieldInvalidError(namespace string, field string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "search.field.invalid",
+		Message: fmt.Sprintf("Invalid search field: %v (%v)", field, namespace),
+		Details: map[string]interface{}{"field": field, "namespace": namespace},
+	}
+}
+
+// SearchOperatorInvalidError returns the error type of the operators not allowed on the search field
+func SearchOperatorInvalidError(field string, op string, allowed []string) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "search.operator.invalid",
+		Message: fmt.Sprintf("Invalid operator of search field %v: %v (allowed %v)", field, op, strings.Join(allowed, ", ")),
+		Details: map[string]interface{}{"field": field, "operator": op, "allowed_operators": allowed},
+	}
+}
+
+// SearchValueInvalidError returns the error type of the values not in the type of the search field
+func SearchValueInvalidError(field string, err error) errors.ApplicationError {
+	return &errors.BaseApplicationError{
+		Code:    "search.value.invalid",
+		Message: fmt.Sprintf("Invalid value of search field %v: %v", field, err),
+		Details: map[string]interface{}{"field": field},
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// ExportTrailer represents the last record of the NDJSON export with the checksums of the exported ledger
+type ExportTrailer struct {
+	Accounts     int    `json:"accounts"`
+	Transactions int    `json:"transactions"`
+	Lines        int    `json:"lines"`
+	BalanceSum   int    `json:"balance_sum"`
+	SHA256       string `json:"sha256"`
+}
+
+// Exporter exports the entire ledger as NDJSON records
+type Exporter struct {
+	db *sql.DB
+}
+
+// NewExporter returns a new instance of `Exporter`
+func NewExporter(db *sql.DB) *Exporter {
+	return &Exporter{db: db}
+}
+
+// Export writes all the accounts ordered by ID and then all the transactions ordered by timestamp and ID,
+// as NDJSON records in the format of the bulk import. The export ends with a trailer record having the counts,
+// the sum of the account balances and the SHA-256 hash of all the preceding lines.
+func (e *Exporter) Export(w io.Writer) error {
+	// Read the entire ledger from the same snapshot
+	tx, err := e.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	hash := sha256.New()
+	encoder := json.NewEncoder(io.MultiWriter(w, hash))
+	trailer := &ExportTrailer{}
+
+	// Export accounts
+	rows, err := tx.Query("SELECT id, balance, data FROM current_balances ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		account := &Account{}
+		var rawData []byte
+		if err := rows.Scan(&account.ID, &account.Balance, &rawData); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(rawData, &account.Data); err != nil {
+			return err
+		}
+		if err := encoder.Encode(&BulkRecord{Account: account}); err != nil {
+			return err
+		}
+		trailer.Accounts++
+		trailer.BalanceSum += account.Balance
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// Export transactions along with their lines
+	rows, err = tx.Query(`SELECT transactions.id, transactions.timestamp, transactions.data, lines.account_id, lines.delta
+			FROM transactions LEFT OUTER JOIN lines ON lines.transaction_id = transactions.id
+			ORDER BY transactions.timestamp, transactions.id, lines.id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var txn *Transaction
+	for rows.Next() {
+		var id string
+		var timestamp time.Time
+		var rawData []byte
+		var accountID sql.NullString
+		var delta sql.NullInt64
+		if err := rows.Scan(&id, &timestamp, &rawData, &accountID, &delta); err != nil {
+			return err
+		}
+		if txn == nil || txn.ID != id {
+			if txn != nil {
+				if err := encoder.Encode(&BulkRecord{Transaction: txn}); err != nil {
+					return err
+				}
+			}
+			txn = &Transaction{ID: id, Timestamp: timestamp.Format(LedgerTimestampLayout), Lines: make([]*TransactionLine, 0)}
+			if err := json.Unmarshal(rawData, &txn.Data); err != nil {
+				return err
+			}
+			trailer.Transactions++
+		}
+		if accountID.Valid {
+			txn.Lines = append(txn.Lines, &TransactionLine{AccountID: accountID.String, Delta: int(delta.Int64)})
+			trailer.Lines++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if txn != nil {
+		if err := encoder.Encode(&BulkRecord{Transaction: txn}); err != nil {
+			return err
+		}
+	}
+
+	trailer.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return json.NewEncoder(w).Encode(&BulkRecord{Trailer: trailer})
+}
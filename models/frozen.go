@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+// FrozenKeys are the keys of the transaction data which can't be changed once they are set.
+// The keys which are missing in the data can still be set by an update.
+type FrozenKeys struct {
+	// Keys are frozen in the data of all the transactions
+	Keys []string `json:"keys"`
+	// Actions have the keys frozen in the data of the transactions with the `action`
+	Actions map[string][]string `json:"actions"`
+}
+
+// ParseFrozenKeys parses the comma separated keys frozen in the data of all the transactions
+func ParseFrozenKeys(value string) (*FrozenKeys, error) {
+	frozen := &FrozenKeys{}
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			frozen.Keys = append(frozen.Keys, key)
+		}
+	}
+	return frozen, frozen.validate()
+}
+
+// LoadFrozenKeysFile loads the frozen keys from the JSON file in the format of `FrozenKeys`
+func LoadFrozenKeysFile(path string) (*FrozenKeys, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	frozen := &FrozenKeys{}
+	if err := json.Unmarshal(data, frozen); err != nil {
+		return nil, err
+	}
+	return frozen, frozen.validate()
+}
+
+func (f *FrozenKeys) validate() error {
+	keys := append([]string{}, f.Keys...)
+	for _, actionKeys := range f.Actions {
+		keys = append(keys, actionKeys...)
+	}
+	for _, key := range keys {
+		if !validDataKey.MatchString(key) {
+			return fmt.Errorf("Invalid frozen key: %v", key)
+		}
+	}
+	return nil
+}
+
+// keysOf returns the keys frozen in the data, which are the global keys and the keys of its `action`
+func (f *FrozenKeys) keysOf(data map[string]interface{}) []string {
+	keys := f.Keys
+	if action, ok := data["action"].(string); ok {
+		keys = append(append([]string{}, keys...), f.Actions[action]...)
+	}
+	return keys
+}
+
+// Check returns the error listing the frozen keys changed by the update of the data from before to after.
+// The keys are frozen by the `action` of the data both before and after the update,
+// so that the keys can't be unfrozen by changing the `action` in the same update.
+func (f *FrozenKeys) Check(id string, before, after map[string]interface{}) ledgerError.ApplicationError {
+	if f == nil {
+		return nil
+	}
+	changed := make(map[string]bool)
+	for _, key := range append(f.keysOf(before), f.keysOf(after)...) {
+		value, ok := before[key]
+		if !ok {
+			continue
+		}
+		if newValue, ok := after[key]; !ok || !reflect.DeepEqual(value, newValue) {
+			changed[key] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return DataKeyFrozenError(id, keys)
+}
+
+// checkFrozenKeys checks the update of the data from before to after in JSON
+func checkFrozenKeys(frozen *FrozenKeys, id string, before, after string) ledgerError.ApplicationError {
+	if frozen == nil {
+		return nil
+	}
+	var beforeData, afterData map[string]interface{}
+	if err := json.Unmarshal([]byte(before), &beforeData); err != nil {
+		return JSONError(err)
+	}
+	if err := json.Unmarshal([]byte(after), &afterData); err != nil {
+		return JSONError(err)
+	}
+	return frozen.Check(id, beforeData, afterData)
+}
+
+// reversalKeys are the keys of the transaction data linking the reversals, which are set only by the reversals
+var reversalKeys = []string{"reverses", "reversed_by"}
+
+// checkReversalKeys returns the error listing the keys of the reversals changed by the update of the data
+// from before to after in JSON. Unlike the frozen keys, the missing keys can't be set by the update either.
+func checkReversalKeys(id string, before, after string) ledgerError.ApplicationError {
+	var beforeData, afterData map[string]interface{}
+	if err := json.Unmarshal([]byte(before), &beforeData); err != nil {
+		return JSONError(err)
+	}
+	if err := json.Unmarshal([]byte(after), &afterData); err != nil {
+		return JSONError(err)
+	}
+	var keys []string
+	for _, key := range reversalKeys {
+		value, ok := beforeData[key]
+		newValue, newOk := afterData[key]
+		if ok != newOk || !reflect.DeepEqual(value, newValue) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) != 0 {
+		return DataKeyFrozenError(id, keys)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrozenKeys(t *testing.T) {
+	frozen, err := ParseFrozenKeys("order_id, invoice_no,")
+	assert.Nil(t, err, "Error while parsing frozen keys")
+	assert.Equal(t, []string{"order_id", "invoice_no"}, frozen.Keys, "Invalid frozen keys")
+	_, err = ParseFrozenKeys("order.id")
+	assert.NotNil(t, err, "Invalid frozen key should not be parsed")
+
+	file, err := ioutil.TempFile("", "frozen_keys")
+	assert.Nil(t, err, "Error while creating frozen keys file")
+	defer os.Remove(file.Name())
+	file.WriteString(`{"keys": ["order_id"], "actions": {"refund": ["refund_id"]}}`)
+	file.Close()
+	frozen, err = LoadFrozenKeysFile(file.Name())
+	assert.Nil(t, err, "Error while loading frozen keys file")
+
+	before := map[string]interface{}{"action": "refund", "order_id": "o1", "refund_id": "r1", "status": "pending"}
+	aerr := frozen.Check("txn1", before, map[string]interface{}{
+		"action": "refund", "order_id": "o1", "refund_id": "r1", "status": "completed", "invoice_no": "i1",
+	})
+	assert.Nil(t, aerr, "Update of the other keys should be allowed")
+
+	aerr = frozen.Check("txn1", before, map[string]interface{}{"action": "sale", "status": "completed"})
+	assert.Equal(t, "data.key.frozen", aerr.ErrorCode(), "Invalid error code")
+	assert.Equal(t, []string{"order_id", "refund_id"}, aerr.ErrorDetails().(map[string]interface{})["keys"], "Invalid frozen keys")
+
+	// The keys frozen by the new action are also checked
+	aerr = frozen.Check("txn1", map[string]interface{}{"refund_id": "r1"}, map[string]interface{}{"action": "refund", "refund_id": "r2"})
+	assert.Equal(t, "data.key.frozen", aerr.ErrorCode(), "Invalid error code")
+
+	// The missing keys can be set once
+	aerr = frozen.Check("txn1", map[string]interface{}{}, map[string]interface{}{"order_id": "o1"})
+	assert.Nil(t, aerr, "Missing frozen key should be allowed to be set")
+
+	var unfrozen *FrozenKeys
+	assert.Nil(t, unfrozen.Check("txn1", before, map[string]interface{}{}), "Keys should not be frozen")
+}
+
+func TestReversalKeys(t *testing.T) {
+	assert.Nil(t, ValidateReservedKeys(map[string]interface{}{"reversal": "r1"}), "Data should be valid")
+	aerr := ValidateReservedKeys(map[string]interface{}{"reverses": "t1"})
+	assert.Equal(t, "data.key.reserved", aerr.ErrorCode(), "Invalid error code")
+
+	before := `{"status": "pending", "reversed_by": "t1-reversal"}`
+	assert.Nil(t, checkReversalKeys("t1", before, `{"status": "completed", "reversed_by": "t1-reversal"}`), "Update of the other keys should be allowed")
+	aerr = checkReversalKeys("t1", before, `{"status": "completed"}`)
+	assert.Equal(t, "data.key.frozen", aerr.ErrorCode(), "Invalid error code")
+	assert.Equal(t, []string{"reversed_by"}, aerr.ErrorDetails().(map[string]interface{})["keys"], "Invalid frozen keys")
+
+	// Unlike the frozen keys, the missing keys can't be set
+	aerr = checkReversalKeys("t1", `{}`, `{"reverses": "t0", "reversed_by": "t1-reversal"}`)
+	assert.Equal(t, []string{"reverses", "reversed_by"}, aerr.ErrorDetails().(map[string]interface{})["keys"], "Invalid frozen keys")
+}
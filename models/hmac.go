@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+// HMACKey represents a named secret key used to sign the requests,
+// with its scopes and the accounts it is restricted to
+type HMACKey struct {
+	Name     string          `json:"name"`
+	Secret   string          `json:"secret"`
+	Scopes   []string        `json:"scopes"`
+	Accounts AccountPatterns `json:"accounts"`
+}
+
+// HMACKeyStore finds the HMAC keys
+type HMACKeyStore interface {
+	GetByName(name string) (*HMACKey, ledgerError.ApplicationError)
+}
+
+// HMACKeyFile provides the HMAC keys loaded from a JSON file
+type HMACKeyFile struct {
+	keys map[string]*HMACKey
+}
+
+// LoadHMACKeyFile loads the HMAC keys from the JSON file having a list of `HMACKey`
+func LoadHMACKeyFile(path string) (*HMACKeyFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*HMACKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+
+	file := &HMACKeyFile{keys: make(map[string]*HMACKey, len(keys))}
+	for _, key := range keys {
+		if key.Name == "" || key.Secret == "" {
+			return nil, fmt.Errorf("Key should have a name and secret: %v", key.Name)
+		}
+		if err := ValidateScopes(key.Scopes); err != nil {
+			return nil, err
+		}
+		file.keys[key.Name] = key
+	}
+	return file, nil
+}
+
+// GetByName returns the HMAC key with the name, or nil if the key doesn't exist
+func (f *HMACKeyFile) GetByName(name string) (*HMACKey, ledgerError.ApplicationError) {
+	return f.keys[name], nil
+}
@@ -0,0 +1,352 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"regexp"
+	"sort"
+	"strings"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/lib/pq"
+)
+
+const (
+	// PatchContentTypeMerge is the content type of the JSON Merge Patch (RFC 7396)
+	PatchContentTypeMerge = "application/merge-patch+json"
+	// PatchContentTypeJSON is the content type of the JSON Patch (RFC 6902)
+	PatchContentTypeJSON = "application/json-patch+json"
+	// maxPatchOperations limits the operations of a JSON Patch, as each operation nests the SQL of the patch
+	maxPatchOperations = 100
+)
+
+// arrayIndex matches the JSON Pointer tokens of the array indices
+var arrayIndex = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+// PatchOperation represents an operation of the JSON Patch
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+
+	path []string
+	from []string
+}
+
+// DataPatch represents a patch of the data of an account or transaction, which is either
+// a JSON Merge Patch or a JSON Patch. The patch is applied by the database in a single UPDATE,
+// so that the concurrent patches of different keys don't overwrite each other.
+type DataPatch struct {
+	ID string
+	// ExpectedVersion is the version the patch expects, unless it is 0
+	ExpectedVersion int64
+
+	merge      map[string]interface{}
+	operations []*PatchOperation
+}
+
+// NewDataPatch parses the patch of the data of the account or transaction with the ID.
+// The content type says whether the patch is a JSON Merge Patch or a JSON Patch.
+func NewDataPatch(id string, contentType string, body []byte) (*DataPatch, ledgerError.ApplicationError) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	patch := &DataPatch{ID: id}
+	switch mediaType {
+	case PatchContentTypeMerge:
+		if err := json.Unmarshal(body, &patch.merge); err != nil {
+			return nil, PatchInvalidError(err)
+		}
+		// The data is always an object, so it can't be replaced by any other value
+		if patch.merge == nil {
+			return nil, PatchInvalidError(errors.New("Merge patch should be an object"))
+		}
+		if aerr := ValidateData(patch.merge); aerr != nil {
+			return nil, aerr
+		}
+	case PatchContentTypeJSON:
+		if err := json.Unmarshal(body, &patch.operations); err != nil {
+			return nil, PatchInvalidError(err)
+		}
+		if len(patch.operations) > maxPatchOperations {
+			return nil, PatchInvalidError(fmt.Errorf("Patch has more than %v operations", maxPatchOperations))
+		}
+		for _, op := range patch.operations {
+			if aerr := op.parse(); aerr != nil {
+				return nil, aerr
+			}
+		}
+	default:
+		return nil, PatchTypeInvalidError(contentType)
+	}
+	return patch, nil
+}
+
+// parsePointer parses the JSON Pointer (RFC 6901) to the path of a value in the data
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, errors.New("Path of the whole data is not allowed")
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("Invalid path: %v", pointer)
+	}
+	path := strings.Split(pointer[1:], "/")
+	for i, token := range path {
+		path[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return path, nil
+}
+
+// parse validates the operation and parses its paths
+func (op *PatchOperation) parse() ledgerError.ApplicationError {
+	var err error
+	if op.path, err = parsePointer(op.Path); err != nil {
+		return PatchInvalidError(err)
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return PatchInvalidError(fmt.Errorf("Missing value of %v operation: %v", op.Op, op.Path))
+		}
+	case "move", "copy":
+		if op.from, err = parsePointer(op.From); err != nil {
+			return PatchInvalidError(err)
+		}
+		if op.Op == "move" && op.From != op.Path && strings.HasPrefix(op.Path, op.From+"/") {
+			return PatchInvalidError(fmt.Errorf("Path can't be moved into itself: %v", op.From))
+		}
+	case "remove":
+	default:
+		return PatchInvalidError(fmt.Errorf("Invalid operation: %v", op.Op))
+	}
+	// The new keys of the data are validated as in the data of the accounts and transactions
+	if len(op.path) == 1 && (op.Op == "add" || op.Op == "move" || op.Op == "copy") {
+		if !validDataKey.MatchString(op.path[0]) {
+			return DataKeyInvalidError(op.path[0])
+		}
+	}
+	return nil
+}
+
+// toSQL returns the SQL expression of the patched `data` with the ? placeholders of its arguments.
+// The expression results in NULL if the JSON Patch can't be applied on the data.
+func (patch *DataPatch) toSQL() (string, []interface{}) {
+	if patch.merge != nil {
+		return mergeToSQL(nil, patch.merge)
+	}
+
+	// The operations are applied in order, each on the result of the previous operation,
+	// by nesting them as subqueries having the data `d` and a value `v` moved by the operation
+	q := "SELECT data AS d, NULL::jsonb AS v"
+	var args []interface{}
+	for i, op := range patch.operations {
+		for j, step := range op.toSQL() {
+			q = fmt.Sprintf("SELECT %s AS d, %s AS v FROM (%s) AS op%d_%d", step.d, step.v, q, i, j)
+			args = append(append(step.args, step.vArgs...), args...)
+		}
+	}
+	return "(SELECT d FROM (" + q + ") AS patch)", args
+}
+
+// mergeToSQL returns the SQL expression of merging the patch into the object at the path of the data.
+// The keys of an object are patched independently of each other, so the nested objects of the patch
+// are merged into the objects at their paths in the original data.
+func mergeToSQL(path []string, patch map[string]interface{}) (string, []interface{}) {
+	q := "data"
+	var args []interface{}
+	if len(path) != 0 {
+		q = "(CASE WHEN jsonb_typeof(data #> ?::text[]) = 'object' THEN data #> ?::text[] ELSE '{}'::jsonb END)"
+		args = append(args, pq.Array(path), pq.Array(path))
+	}
+
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var removed, nested []string
+	values := make(map[string]interface{})
+	for _, key := range keys {
+		switch value := patch[key].(type) {
+		case nil:
+			removed = append(removed, key)
+		case map[string]interface{}:
+			nested = append(nested, key)
+		default:
+			values[key] = value
+		}
+	}
+
+	if len(removed) != 0 {
+		q = "(" + q + " - ?::text[])"
+		args = append(args, pq.Array(removed))
+	}
+	if len(values) != 0 {
+		q = "(" + q + " || ?::jsonb)"
+		args = append(args, jsonify(values))
+	}
+	for _, key := range nested {
+		nestedPath := append(append([]string{}, path...), key)
+		nestedQ, nestedArgs := mergeToSQL(nestedPath, patch[key].(map[string]interface{}))
+		q = "jsonb_set(" + q + ", ?::text[], " + nestedQ + ")"
+		args = append(args, pq.Array([]string{key}))
+		args = append(args, nestedArgs...)
+	}
+	return q, args
+}
+
+// patchStep is the SQL of a step of an operation, which results in the data `d` and the moved value `v`
+type patchStep struct {
+	d     string
+	args  []interface{}
+	v     string
+	vArgs []interface{}
+}
+
+// toSQL returns the steps of the operation on the data `d` of the previous step
+func (op *PatchOperation) toSQL() []patchStep {
+	switch op.Op {
+	case "add":
+		q, args := addToSQL(op.path, "?::jsonb", []interface{}{string(op.Value)})
+		return []patchStep{{d: q, args: args, v: "NULL::jsonb"}}
+	case "remove":
+		q := "CASE WHEN d #> ?::text[] IS NOT NULL THEN d #- ?::text[] END"
+		return []patchStep{{d: q, args: []interface{}{pq.Array(op.path), pq.Array(op.path)}, v: "NULL::jsonb"}}
+	case "replace":
+		q := "CASE WHEN d #> ?::text[] IS NOT NULL THEN jsonb_set(d, ?::text[], ?::jsonb, false) END"
+		return []patchStep{{d: q, args: []interface{}{pq.Array(op.path), pq.Array(op.path), string(op.Value)}, v: "NULL::jsonb"}}
+	case "test":
+		q := "CASE WHEN d #> ?::text[] = ?::jsonb THEN d END"
+		return []patchStep{{d: q, args: []interface{}{pq.Array(op.path), string(op.Value)}, v: "NULL::jsonb"}}
+	case "copy":
+		q, args := addToSQL(op.path, "d #> ?::text[]", []interface{}{pq.Array(op.from)})
+		return []patchStep{{d: q, args: args, v: "NULL::jsonb"}}
+	case "move":
+		// The value is removed from its path and kept in `v` to be added to the new path
+		remove := patchStep{
+			d:     "CASE WHEN d #> ?::text[] IS NOT NULL THEN d #- ?::text[] END",
+			args:  []interface{}{pq.Array(op.from), pq.Array(op.from)},
+			v:     "d #> ?::text[]",
+			vArgs: []interface{}{pq.Array(op.from)},
+		}
+		q, args := addToSQL(op.path, "v", nil)
+		return []patchStep{remove, {d: q, args: args, v: "NULL::jsonb"}}
+	}
+	return nil
+}
+
+// addToSQL returns the SQL of adding the value to the path of the data `d`.
+// The value is set to the key of an object, or inserted at the index of an array.
+func addToSQL(path []string, value string, valueArgs []interface{}) (string, []interface{}) {
+	parent := path[:len(path)-1]
+	last := path[len(path)-1]
+
+	q := "CASE WHEN " + value + " IS NULL THEN NULL"
+	args := append([]interface{}{}, valueArgs...)
+	q += " WHEN jsonb_typeof(d #> ?::text[]) = 'object' THEN jsonb_set(d, ?::text[], " + value + ", true)"
+	args = append(args, pq.Array(parent), pq.Array(path))
+	args = append(args, valueArgs...)
+	switch {
+	case last == "-":
+		// The value is appended to the array
+		q += " WHEN jsonb_typeof(d #> ?::text[]) = 'array' THEN jsonb_set(d, ?::text[], (d #> ?::text[]) || jsonb_build_array(" + value + "))"
+		args = append(args, pq.Array(parent), pq.Array(parent), pq.Array(parent))
+		args = append(args, valueArgs...)
+	case arrayIndex.MatchString(last):
+		q += " WHEN jsonb_typeof(d #> ?::text[]) = 'array' AND ?::int <= jsonb_array_length(d #> ?::text[])" +
+			" THEN jsonb_insert(d, ?::text[], " + value + ")"
+		args = append(args, pq.Array(parent), last, pq.Array(parent), pq.Array(path))
+		args = append(args, valueArgs...)
+	}
+	return q + " END", args
+}
+
+// isNotNullViolation says whether the error is caused by violating a not-null constraint
+func isNotNullViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code.Name() == "not_null_violation"
+}
+
+// patchData applies the patch on the data of an account or transaction, and records the change
+// in its version history and the audit log in the same DB transaction.
+// The patch changing the frozen keys of the data, or resulting in the data violating its schema, is rolled back.
+func patchData(db *sql.DB, audit *AuditInfo, frozenKeys *FrozenKeys, schemas *DataSchemas, entityType string, patch *DataPatch) ledgerError.ApplicationError {
+	table, notFound := "accounts", AccountNotFoundError
+	if entityType == AuditEntityTransaction {
+		table, notFound = "transactions", TransactionNotFoundError
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return DBError(err)
+	}
+	defer tx.Rollback()
+
+	// Lock the row to record the data it had before the patch
+	var before string
+	var current int64
+	err = tx.QueryRow("SELECT data, version FROM "+table+" WHERE id = $1 FOR UPDATE", patch.ID).Scan(&before, &current)
+	switch {
+	case err == sql.ErrNoRows:
+		return notFound(patch.ID)
+	case err != nil:
+		return DBError(err)
+	}
+
+	// The patch which can't be applied results in NULL data, which is rejected by the not-null constraint
+	data, args := patch.toSQL()
+	q := "UPDATE " + table + " SET data = " + data + ", version = version + 1" +
+		" WHERE id = ? AND (?::bigint = 0 OR version = ?::bigint) RETURNING data"
+	args = append(args, patch.ID, patch.ExpectedVersion, patch.ExpectedVersion)
+	var after string
+	err = tx.QueryRow(enumerateSQLPlacholder(q), args...).Scan(&after)
+	switch {
+	case err == sql.ErrNoRows:
+		return VersionMismatchError(patch.ID, patch.ExpectedVersion, current)
+	case isNotNullViolation(err):
+		return PatchConflictError(patch.ID)
+	case err != nil:
+		return DBError(err)
+	}
+	if aerr := checkFrozenKeys(frozenKeys, patch.ID, before, after); aerr != nil {
+		return aerr
+	}
+	if entityType == AuditEntityTransaction {
+		if aerr := checkReversalKeys(patch.ID, before, after); aerr != nil {
+			return aerr
+		}
+	}
+	if aerr := validateDataSchema(schemas, entityType, after); aerr != nil {
+		return aerr
+	}
+
+	err = recordVersion(tx, entityType, patch.ID)
+	if err != nil {
+		return DBError(err)
+	}
+	err = writeAudit(tx, audit, entityType, patch.ID, AuditActionUpdate, before, after)
+	if err != nil {
+		return DBError(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return DBError(err)
+	}
+	return nil
+}
+
+// PatchAccount applies the patch on the data of the account.
+// The account is patched only if it has the expected version, when the expected version is set.
+func (a *AccountDB) PatchAccount(patch *DataPatch) ledgerError.ApplicationError {
+	return patchData(a.db, a.audit, nil, a.schemas, AuditEntityAccount, patch)
+}
+
+// PatchTransaction applies the patch on the data of the transaction.
+// The transaction is patched only if it has the expected version, when the expected version is set,
+// and the patch doesn't change the frozen keys of the data.
+func (t *TransactionDB) PatchTransaction(patch *DataPatch) ledgerError.ApplicationError {
+	return patchData(t.db, t.audit, t.frozenKeys, t.schemas, AuditEntityTransaction, patch)
+}
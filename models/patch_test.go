@@ -0,0 +1,180 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNewDataPatch(t *testing.T) {
+	patch, aerr := NewDataPatch("acc-1", "application/merge-patch+json; charset=utf-8",
+		[]byte(`{"status": "active", "old": null, "meta": {"tag": "x"}}`))
+	assert.Nil(t, aerr, "Error while parsing merge patch")
+	q, args := patch.toSQL()
+	assert.Equal(t, "jsonb_set(((data - ?::text[]) || ?::jsonb), ?::text[], "+
+		"((CASE WHEN jsonb_typeof(data #> ?::text[]) = 'object' THEN data #> ?::text[] ELSE '{}'::jsonb END) || ?::jsonb))", q, "Invalid SQL")
+	assert.Equal(t, 6, len(args), "Invalid SQL args")
+	assert.Equal(t, `{"status":"active"}`, args[1], "Invalid SQL args")
+	assert.Equal(t, `{"tag":"x"}`, args[5], "Invalid SQL args")
+
+	patch, aerr = NewDataPatch("acc-1", "application/json-patch+json", []byte(`[
+		{"op": "test", "path": "/status", "value": "new"},
+		{"op": "replace", "path": "/status", "value": "active"}
+	]`))
+	assert.Nil(t, aerr, "Error while parsing JSON patch")
+	q, args = patch.toSQL()
+	assert.Equal(t, "(SELECT d FROM (SELECT CASE WHEN d #> ?::text[] IS NOT NULL THEN jsonb_set(d, ?::text[], ?::jsonb, false) END AS d, NULL::jsonb AS v "+
+		"FROM (SELECT CASE WHEN d #> ?::text[] = ?::jsonb THEN d END AS d, NULL::jsonb AS v "+
+		"FROM (SELECT data AS d, NULL::jsonb AS v) AS op0_0) AS op1_0) AS patch)", q, "Invalid SQL")
+	assert.Equal(t, `"active"`, args[2], "Invalid SQL args")
+	assert.Equal(t, `"new"`, args[4], "Invalid SQL args")
+
+	patch, aerr = NewDataPatch("acc-1", "application/json-patch+json", []byte(`[{"op": "add", "path": "/meta/a~1b~0c", "value": 1}]`))
+	assert.Nil(t, aerr, "Error while parsing JSON patch")
+	assert.Equal(t, []string{"meta", "a/b~c"}, patch.operations[0].path, "Invalid patch path")
+
+	_, aerr = NewDataPatch("acc-1", "application/json", []byte(`{}`))
+	assert.Equal(t, "patch.type.invalid", aerr.ErrorCode(), "Invalid error code")
+	_, aerr = NewDataPatch("acc-1", PatchContentTypeMerge, []byte(`null`))
+	assert.Equal(t, "patch.invalid", aerr.ErrorCode(), "Invalid error code")
+	_, aerr = NewDataPatch("acc-1", PatchContentTypeJSON, []byte(`[{"op": "add", "path": "/status"}]`))
+	assert.Equal(t, "patch.invalid", aerr.ErrorCode(), "Invalid error code")
+	_, aerr = NewDataPatch("acc-1", PatchContentTypeJSON, []byte(`[{"op": "remove", "path": ""}]`))
+	assert.Equal(t, "patch.invalid", aerr.ErrorCode(), "Invalid error code")
+	_, aerr = NewDataPatch("acc-1", PatchContentTypeJSON, []byte(`[{"op": "move", "from": "/a", "path": "/a/b"}]`))
+	assert.Equal(t, "patch.invalid", aerr.ErrorCode(), "Invalid error code")
+	_, aerr = NewDataPatch("acc-1", PatchContentTypeJSON, []byte(`[{"op": "merge", "path": "/a"}]`))
+	assert.Equal(t, "patch.invalid", aerr.ErrorCode(), "Invalid error code")
+}
+
+type PatchSuite struct {
+	suite.Suite
+	db *sql.DB
+}
+
+func (ps *PatchSuite) SetupTest() {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	assert.NotEmpty(ps.T(), databaseURL)
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Panic("Unable to connect to Database:", err)
+	} else {
+		log.Println("Successfully established connection to database.")
+		ps.db = db
+	}
+}
+
+func (ps *PatchSuite) TestPatchAccount() {
+	t := ps.T()
+
+	accountsDB := NewAccountDB(ps.db)
+	aerr := accountsDB.CreateAccount(&Account{ID: "patch-acc-1", Data: map[string]interface{}{
+		"status": "new", "old": true, "meta": map[string]interface{}{"tag": "a", "rank": 1}, "tags": []string{"x"},
+	}})
+	assert.Nil(t, aerr, "Error while creating account")
+
+	patch, aerr := NewDataPatch("patch-acc-1", PatchContentTypeMerge, []byte(`{"status": "active", "old": null, "meta": {"tag": "b"}}`))
+	assert.Nil(t, aerr, "Error while parsing merge patch")
+	aerr = accountsDB.PatchAccount(patch)
+	assert.Nil(t, aerr, "Error while patching account")
+	account, aerr := accountsDB.GetResultByID("patch-acc-1", "")
+	assert.Nil(t, aerr, "Error while getting account")
+	assert.Equal(t, map[string]interface{}{
+		"status": "active", "meta": map[string]interface{}{"tag": "b", "rank": float64(1)}, "tags": []interface{}{"x"},
+	}, account.Data, "Invalid patched data")
+	assert.Equal(t, int64(2), account.Version, "Invalid account version")
+
+	patch, aerr = NewDataPatch("patch-acc-1", PatchContentTypeJSON, []byte(`[
+		{"op": "test", "path": "/status", "value": "active"},
+		{"op": "add", "path": "/tags/-", "value": "z"},
+		{"op": "add", "path": "/tags/1", "value": "y"},
+		{"op": "move", "from": "/meta/rank", "path": "/rank"},
+		{"op": "copy", "from": "/meta/tag", "path": "/tag"},
+		{"op": "remove", "path": "/meta"}
+	]`))
+	assert.Nil(t, aerr, "Error while parsing JSON patch")
+	patch.ExpectedVersion = 2
+	aerr = accountsDB.PatchAccount(patch)
+	assert.Nil(t, aerr, "Error while patching account")
+	account, aerr = accountsDB.GetResultByID("patch-acc-1", "")
+	assert.Nil(t, aerr, "Error while getting account")
+	assert.Equal(t, map[string]interface{}{
+		"status": "active", "tags": []interface{}{"x", "y", "z"}, "rank": float64(1), "tag": "b",
+	}, account.Data, "Invalid patched data")
+
+	// The patch expecting a stale version is rejected
+	aerr = accountsDB.PatchAccount(patch)
+	assert.Equal(t, "version.mismatch", aerr.ErrorCode(), "Invalid error code")
+
+	// The patch which can't be applied leaves the data unchanged
+	for _, body := range []string{
+		`[{"op": "test", "path": "/status", "value": "closed"}]`,
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "add", "path": "/tags/5", "value": "w"}]`,
+		`[{"op": "add", "path": "/missing/key", "value": "w"}]`,
+	} {
+		patch, aerr = NewDataPatch("patch-acc-1", PatchContentTypeJSON, []byte(body))
+		assert.Nil(t, aerr, "Error while parsing JSON patch")
+		aerr = accountsDB.PatchAccount(patch)
+		assert.Equal(t, "patch.conflict", aerr.ErrorCode(), "Invalid error code")
+	}
+	account, aerr = accountsDB.GetResultByID("patch-acc-1", "")
+	assert.Nil(t, aerr, "Error while getting account")
+	assert.Equal(t, int64(3), account.Version, "Invalid account version")
+
+	patch, aerr = NewDataPatch("patch-acc-missing", PatchContentTypeMerge, []byte(`{}`))
+	assert.Nil(t, aerr, "Error while parsing merge patch")
+	aerr = accountsDB.PatchAccount(patch)
+	assert.Equal(t, "account.notfound", aerr.ErrorCode(), "Invalid error code")
+}
+
+func (ps *PatchSuite) TestPatchTransaction() {
+	t := ps.T()
+
+	transactionDB := NewTransactionDB(ps.db)
+	done := transactionDB.Transact(&Transaction{
+		ID:   "patch-txn-1",
+		Data: map[string]interface{}{"status": "pending"},
+		Lines: []*TransactionLine{
+			&TransactionLine{AccountID: "patch-acc-2", Delta: 100},
+			&TransactionLine{AccountID: "patch-acc-3", Delta: -100},
+		},
+	})
+	assert.Equal(t, true, done, "Transaction should be created")
+
+	patch, aerr := NewDataPatch("patch-txn-1", PatchContentTypeMerge, []byte(`{"status": "settled"}`))
+	assert.Nil(t, aerr, "Error while parsing merge patch")
+	auditedDB := transactionDB.WithAudit(&AuditInfo{Identity: "patch-test"})
+	aerr = auditedDB.PatchTransaction(patch)
+	assert.Nil(t, aerr, "Error while patching transaction")
+
+	versions, aerr := transactionDB.GetVersions("patch-txn-1")
+	assert.Nil(t, aerr, "Error while getting transaction versions")
+	assert.Equal(t, 2, len(versions), "Invalid number of versions")
+	assert.JSONEq(t, `{"status": "settled"}`, string(versions[1].Data), "Invalid version data")
+}
+
+func (ps *PatchSuite) TearDownSuite() {
+	t := ps.T()
+	_, err := ps.db.Exec("DELETE FROM lines WHERE transaction_id LIKE $1", "patch-txn-%")
+	if err != nil {
+		t.Fatal("Error deleting lines:", err)
+	}
+	_, err = ps.db.Exec("DELETE FROM transactions WHERE id LIKE $1", "patch-txn-%")
+	if err != nil {
+		t.Fatal("Error deleting transactions:", err)
+	}
+	_, err = ps.db.Exec("DELETE FROM accounts WHERE id LIKE $1", "patch-acc-%")
+	if err != nil {
+		t.Fatal("Error deleting accounts:", err)
+	}
+}
+
+func TestPatchSuite(t *testing.T) {
+	suite.Run(t, new(PatchSuite))
+}
@@ -0,0 +1,433 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+// DataSchemas are the JSON Schemas of the data of the accounts and transactions
+type DataSchemas struct {
+	Accounts     *SchemaSet `json:"accounts"`
+	Transactions *SchemaSet `json:"transactions"`
+}
+
+// SchemaSet has the JSON Schemas of the data selected by the value of its discriminator key,
+// such as `type` of the accounts or `action` of the transactions
+type SchemaSet struct {
+	Discriminator string                 `json:"discriminator"`
+	Schemas       map[string]*JSONSchema `json:"schemas"`
+	// Default is the schema of the data which doesn't have a schema of its discriminator, if set
+	Default *JSONSchema `json:"default"`
+}
+
+// SchemaViolation represents a violation of the JSON Schema at the path of the data
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// LoadDataSchemasFile loads the JSON Schemas from the JSON file in the format of `DataSchemas`.
+// The accounts are discriminated by `type` and the transactions by `action` by default.
+func LoadDataSchemasFile(path string) (*DataSchemas, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schemas := &DataSchemas{}
+	if err := json.Unmarshal(data, schemas); err != nil {
+		return nil, err
+	}
+	if schemas.Accounts != nil && schemas.Accounts.Discriminator == "" {
+		schemas.Accounts.Discriminator = "type"
+	}
+	if schemas.Transactions != nil && schemas.Transactions.Discriminator == "" {
+		schemas.Transactions.Discriminator = "action"
+	}
+	return schemas, nil
+}
+
+// Validate validates the data of an account or transaction against the schema selected by its discriminator.
+// The data is not validated if there is no schema selected.
+func (s *DataSchemas) Validate(entityType string, data map[string]interface{}) ledgerError.ApplicationError {
+	if s == nil {
+		return nil
+	}
+	set := s.Accounts
+	if entityType == AuditEntityTransaction {
+		set = s.Transactions
+	}
+	if set == nil {
+		return nil
+	}
+
+	name, _ := data[set.Discriminator].(string)
+	schema, ok := set.Schemas[name]
+	if !ok {
+		name, schema = "default", set.Default
+	}
+	if schema == nil {
+		return nil
+	}
+	// The data is validated as JSON, irrespective of the Go types it is made of
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return JSONError(err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return JSONError(err)
+	}
+	if violations := schema.validate("", value, nil); len(violations) != 0 {
+		return DataSchemaInvalidError(name, violations)
+	}
+	return nil
+}
+
+// validateDataSchema validates the data of an account or transaction in JSON
+func validateDataSchema(schemas *DataSchemas, entityType string, data string) ledgerError.ApplicationError {
+	if schemas == nil {
+		return nil
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return JSONError(err)
+	}
+	return schemas.Validate(entityType, value)
+}
+
+// JSONSchema is the subset of the JSON Schema (draft 7) supported in validating the data.
+// The schemas having any other keyword, such as `$ref`, are rejected while loading with the list of those keywords.
+type JSONSchema struct {
+	// The annotations are not validated
+	Schema      string        `json:"$schema"`
+	ID          string        `json:"$id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Comment     string        `json:"$comment"`
+	Default     interface{}   `json:"default"`
+	Examples    []interface{} `json:"examples"`
+
+	Type  schemaTypes     `json:"type"`
+	Enum  []interface{}   `json:"enum"`
+	Const json.RawMessage `json:"const"`
+
+	Properties           map[string]*JSONSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties"`
+	MinProperties        *int                   `json:"minProperties"`
+	MaxProperties        *int                   `json:"maxProperties"`
+
+	Items       *JSONSchema `json:"items"`
+	MinItems    *int        `json:"minItems"`
+	MaxItems    *int        `json:"maxItems"`
+	UniqueItems bool        `json:"uniqueItems"`
+
+	Minimum          *float64 `json:"minimum"`
+	Maximum          *float64 `json:"maximum"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum"`
+	MultipleOf       *float64 `json:"multipleOf"`
+
+	MinLength *int   `json:"minLength"`
+	MaxLength *int   `json:"maxLength"`
+	Pattern   string `json:"pattern"`
+	Format    string `json:"format"`
+
+	AllOf []*JSONSchema `json:"allOf"`
+	AnyOf []*JSONSchema `json:"anyOf"`
+	OneOf []*JSONSchema `json:"oneOf"`
+	Not   *JSONSchema   `json:"not"`
+
+	// never is set for the schema `false`, which doesn't allow any value
+	never   bool
+	constV  interface{}
+	pattern *regexp.Regexp
+}
+
+// UnmarshalJSON parses the schema, which is either an object or a boolean
+func (s *JSONSchema) UnmarshalJSON(data []byte) error {
+	var allows bool
+	if err := json.Unmarshal(data, &allows); err == nil {
+		*s = JSONSchema{never: !allows}
+		return nil
+	}
+
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return fmt.Errorf("Invalid JSON Schema: %v", err)
+	}
+	var unsupported []string
+	for keyword := range keywords {
+		if !schemaKeywords[keyword] {
+			unsupported = append(unsupported, keyword)
+		}
+	}
+	if len(unsupported) != 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("Unsupported JSON Schema keywords: %v", strings.Join(unsupported, ", "))
+	}
+
+	type schema JSONSchema
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode((*schema)(s)); err != nil {
+		return fmt.Errorf("Invalid JSON Schema: %v", err)
+	}
+	if s.Const != nil {
+		if err := json.Unmarshal(s.Const, &s.constV); err != nil {
+			return err
+		}
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid JSON Schema pattern: %v", err)
+		}
+		s.pattern = pattern
+	}
+	for _, t := range s.Type {
+		if _, ok := schemaTypeCheckers[t]; !ok {
+			return fmt.Errorf("Invalid JSON Schema type: %v", t)
+		}
+	}
+	return nil
+}
+
+// schemaKeywords are the supported keywords of the schemas, which are the JSON keys of the fields of `JSONSchema`
+var schemaKeywords = func() map[string]bool {
+	keywords := make(map[string]bool)
+	schemaType := reflect.TypeOf(JSONSchema{})
+	for i := 0; i < schemaType.NumField(); i++ {
+		if tag := schemaType.Field(i).Tag.Get("json"); tag != "" {
+			keywords[tag] = true
+		}
+	}
+	return keywords
+}()
+
+// schemaTypes are the types of the schema, which is either a type or a list of types
+type schemaTypes []string
+
+// UnmarshalJSON parses the type or the list of types
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaTypes{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return errors.New("Type should be a string or a list of strings")
+	}
+	*t = names
+	return nil
+}
+
+var schemaTypeCheckers = map[string]func(interface{}) bool{
+	"null":    func(v interface{}) bool { return v == nil },
+	"boolean": func(v interface{}) bool { _, ok := v.(bool); return ok },
+	"string":  func(v interface{}) bool { _, ok := v.(string); return ok },
+	"number":  func(v interface{}) bool { _, ok := v.(float64); return ok },
+	"integer": func(v interface{}) bool { n, ok := v.(float64); return ok && n == math.Trunc(n) },
+	"array":   func(v interface{}) bool { _, ok := v.([]interface{}); return ok },
+	"object":  func(v interface{}) bool { _, ok := v.(map[string]interface{}); return ok },
+}
+
+// schemaFormats are the validated formats of the strings. The other formats are not validated.
+var schemaFormats = map[string]func(string) bool{
+	"date":      func(v string) bool { _, err := time.Parse("2006-01-02", v); return err == nil },
+	"date-time": func(v string) bool { _, err := time.Parse(time.RFC3339, v); return err == nil },
+}
+
+// pointerEscaper escapes the keys in the JSON Pointers of the violations
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// validate appends the violations of the value at the path to the violations
+func (s *JSONSchema) validate(path string, value interface{}, violations []*SchemaViolation) []*SchemaViolation {
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, &SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.never {
+		violate("Value is not allowed")
+		return violations
+	}
+
+	if len(s.Type) != 0 {
+		matches := false
+		for _, t := range s.Type {
+			matches = matches || schemaTypeCheckers[t](value)
+		}
+		if !matches {
+			violate("Value should be of type %v", strings.Join(s.Type, " or "))
+			return violations
+		}
+	}
+	if s.Enum != nil {
+		found := false
+		for _, option := range s.Enum {
+			found = found || reflect.DeepEqual(option, value)
+		}
+		if !found {
+			violate("Value should be one of the enum values")
+		}
+	}
+	if s.Const != nil && !reflect.DeepEqual(s.constV, value) {
+		violate("Value should be %s", s.Const)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		violations = s.validateObject(path, v, violations)
+	case []interface{}:
+		violations = s.validateArray(path, v, violations)
+	case float64:
+		violations = s.validateNumber(path, v, violations)
+	case string:
+		violations = s.validateString(path, v, violations)
+	}
+
+	for _, schema := range s.AllOf {
+		violations = schema.validate(path, value, violations)
+	}
+	if s.AnyOf != nil {
+		matches := 0
+		for _, schema := range s.AnyOf {
+			if len(schema.validate(path, value, nil)) == 0 {
+				matches++
+			}
+		}
+		if matches == 0 {
+			violate("Value should match any of the schemas")
+		}
+	}
+	if s.OneOf != nil {
+		matches := 0
+		for _, schema := range s.OneOf {
+			if len(schema.validate(path, value, nil)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			violate("Value should match exactly one of the schemas, but matches %v", matches)
+		}
+	}
+	if s.Not != nil && len(s.Not.validate(path, value, nil)) == 0 {
+		violate("Value should not match the schema")
+	}
+	return violations
+}
+
+func (s *JSONSchema) validateObject(path string, value map[string]interface{}, violations []*SchemaViolation) []*SchemaViolation {
+	for _, key := range s.Required {
+		if _, ok := value[key]; !ok {
+			violations = append(violations, &SchemaViolation{Path: path, Message: "Missing required key: " + key})
+		}
+	}
+	if s.MinProperties != nil && len(value) < *s.MinProperties {
+		violations = append(violations, &SchemaViolation{Path: path, Message: fmt.Sprintf("Object should have at least %v keys", *s.MinProperties)})
+	}
+	if s.MaxProperties != nil && len(value) > *s.MaxProperties {
+		violations = append(violations, &SchemaViolation{Path: path, Message: fmt.Sprintf("Object should have at most %v keys", *s.MaxProperties)})
+	}
+
+	// The keys are validated in order, so that the violations are in a stable order
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := path + "/" + pointerEscaper.Replace(key)
+		if schema, ok := s.Properties[key]; ok {
+			violations = schema.validate(keyPath, value[key], violations)
+		} else if s.AdditionalProperties != nil {
+			if s.AdditionalProperties.never {
+				violations = append(violations, &SchemaViolation{Path: keyPath, Message: "Key is not allowed"})
+				continue
+			}
+			violations = s.AdditionalProperties.validate(keyPath, value[key], violations)
+		}
+	}
+	return violations
+}
+
+func (s *JSONSchema) validateArray(path string, value []interface{}, violations []*SchemaViolation) []*SchemaViolation {
+	if s.MinItems != nil && len(value) < *s.MinItems {
+		violations = append(violations, &SchemaViolation{Path: path, Message: fmt.Sprintf("Array should have at least %v items", *s.MinItems)})
+	}
+	if s.MaxItems != nil && len(value) > *s.MaxItems {
+		violations = append(violations, &SchemaViolation{Path: path, Message: fmt.Sprintf("Array should have at most %v items", *s.MaxItems)})
+	}
+	if s.UniqueItems {
+		for i := range value {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(value[i], value[j]) {
+					violations = append(violations, &SchemaViolation{Path: path, Message: "Array should have unique items"})
+					break
+				}
+			}
+		}
+	}
+	if s.Items != nil {
+		for i, item := range value {
+			violations = s.Items.validate(fmt.Sprintf("%v/%v", path, i), item, violations)
+		}
+	}
+	return violations
+}
+
+func (s *JSONSchema) validateNumber(path string, value float64, violations []*SchemaViolation) []*SchemaViolation {
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, &SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.Minimum != nil && value < *s.Minimum {
+		violate("Value should be at least %v", *s.Minimum)
+	}
+	if s.Maximum != nil && value > *s.Maximum {
+		violate("Value should be at most %v", *s.Maximum)
+	}
+	if s.ExclusiveMinimum != nil && value <= *s.ExclusiveMinimum {
+		violate("Value should be greater than %v", *s.ExclusiveMinimum)
+	}
+	if s.ExclusiveMaximum != nil && value >= *s.ExclusiveMaximum {
+		violate("Value should be less than %v", *s.ExclusiveMaximum)
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		if quotient := value / *s.MultipleOf; quotient != math.Trunc(quotient) {
+			violate("Value should be a multiple of %v", *s.MultipleOf)
+		}
+	}
+	return violations
+}
+
+func (s *JSONSchema) validateString(path string, value string, violations []*SchemaViolation) []*SchemaViolation {
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, &SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		violate("String should have at least %v characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		violate("String should have at most %v characters", *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		violate("String should match the pattern %v", s.Pattern)
+	}
+	if valid, ok := schemaFormats[s.Format]; ok && !valid(value) {
+		violate("String should be in the format %v", s.Format)
+	}
+	return violations
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchemas = `{
+	"accounts": {
+		"default": {"type": "object", "required": ["type"]}
+	},
+	"transactions": {
+		"schemas": {
+			"refund": {
+				"$schema": "http://json-schema.org/draft-07/schema#",
+				"type": "object",
+				"required": ["action", "amount", "order_id"],
+				"properties": {
+					"action": {"const": "refund"},
+					"amount": {"type": "integer", "exclusiveMinimum": 0},
+					"order_id": {"type": "string", "pattern": "^ORD-[0-9]+$"},
+					"reason": {"enum": ["damaged", "late"]},
+					"date": {"type": "string", "format": "date"},
+					"tags": {"type": "array", "items": {"type": "string", "maxLength": 3}, "uniqueItems": true},
+					"meta": {"type": "object", "additionalProperties": false, "properties": {"source": true}}
+				},
+				"additionalProperties": false
+			}
+		}
+	}
+}`
+
+func loadTestSchemas(t *testing.T, content string) (*DataSchemas, error) {
+	file, err := ioutil.TempFile("", "schemas")
+	assert.Nil(t, err, "Error while creating schemas file")
+	defer os.Remove(file.Name())
+	file.WriteString(content)
+	file.Close()
+	return LoadDataSchemasFile(file.Name())
+}
+
+func TestDataSchemas(t *testing.T) {
+	schemas, err := loadTestSchemas(t, testSchemas)
+	assert.Nil(t, err, "Error while loading schemas file")
+	assert.Equal(t, "type", schemas.Accounts.Discriminator, "Invalid default discriminator")
+	assert.Equal(t, "action", schemas.Transactions.Discriminator, "Invalid default discriminator")
+
+	aerr := schemas.Validate(AuditEntityTransaction, map[string]interface{}{
+		"action": "refund", "amount": 100, "order_id": "ORD-1", "reason": "late", "date": "2017-01-01",
+		"tags": []string{"a", "b"}, "meta": map[string]interface{}{"source": "web"},
+	})
+	assert.Nil(t, aerr, "Valid data should not have violations")
+
+	aerr = schemas.Validate(AuditEntityTransaction, map[string]interface{}{
+		"action": "refund", "amount": 1.5, "order_id": "1", "reason": "other", "date": "01/01/2017",
+		"tags": []string{"a", "a", "long"}, "meta": map[string]interface{}{"channel": "web"}, "extra": true,
+	})
+	assert.Equal(t, "data.schema.invalid", aerr.ErrorCode(), "Invalid error code")
+	details := aerr.ErrorDetails().(map[string]interface{})
+	assert.Equal(t, "refund", details["schema"], "Invalid schema")
+	assert.Equal(t, []*SchemaViolation{
+		&SchemaViolation{Path: "/amount", Message: "Value should be of type integer"},
+		&SchemaViolation{Path: "/date", Message: "String should be in the format date"},
+		&SchemaViolation{Path: "/extra", Message: "Key is not allowed"},
+		&SchemaViolation{Path: "/meta/channel", Message: "Key is not allowed"},
+		&SchemaViolation{Path: "/order_id", Message: "String should match the pattern ^ORD-[0-9]+$"},
+		&SchemaViolation{Path: "/reason", Message: "Value should be one of the enum values"},
+		&SchemaViolation{Path: "/tags", Message: "Array should have unique items"},
+		&SchemaViolation{Path: "/tags/2", Message: "String should have at most 3 characters"},
+	}, details["violations"], "Invalid violations")
+
+	aerr = schemas.Validate(AuditEntityTransaction, map[string]interface{}{"action": "refund"})
+	assert.Equal(t, 2, len(aerr.ErrorDetails().(map[string]interface{})["violations"].([]*SchemaViolation)), "Invalid violations")
+
+	// The data without a schema is not validated, unless there is a default schema
+	aerr = schemas.Validate(AuditEntityTransaction, map[string]interface{}{"action": "sale"})
+	assert.Nil(t, aerr, "Data without a schema should not be validated")
+	aerr = schemas.Validate(AuditEntityAccount, map[string]interface{}{"status": "active"})
+	assert.Equal(t, "data.schema.invalid", aerr.ErrorCode(), "Invalid error code")
+	assert.Equal(t, "default", aerr.ErrorDetails().(map[string]interface{})["schema"], "Invalid schema")
+
+	var noSchemas *DataSchemas
+	assert.Nil(t, noSchemas.Validate(AuditEntityAccount, nil), "Data should not be validated")
+
+	// The unsupported keywords are rejected
+	_, err = loadTestSchemas(t, `{"accounts": {"schemas": {"a": {"$ref": "#/definitions/a"}}}}`)
+	assert.NotNil(t, err, "Schema with unsupported keyword should not be loaded")
+	_, err = loadTestSchemas(t, `{"accounts": {"default": {"type": "object", "properties": {"a": {"if": true, "then": false}}}}}`)
+	assert.NotNil(t, err, "Schema with unsupported keywords should not be loaded")
+	assert.Contains(t, err.Error(), "Unsupported JSON Schema keywords: if, then", "Error should list the unsupported keywords")
+	_, err = loadTestSchemas(t, `{"accounts": {"schemas": {"a": {"type": "decimal"}}}}`)
+	assert.NotNil(t, err, "Schema with invalid type should not be loaded")
+}
+
+func TestJSONSchemaCombinators(t *testing.T) {
+	schemas, err := loadTestSchemas(t, `{"accounts": {"default": {
+		"properties": {
+			"limit": {"anyOf": [{"type": "null"}, {"type": "number", "minimum": 0}]},
+			"code": {"oneOf": [{"type": "string"}, {"type": "string", "minLength": 2}]},
+			"status": {"allOf": [{"type": "string"}, {"not": {"const": "deleted"}}]}
+		}
+	}}}`)
+	assert.Nil(t, err, "Error while loading schemas file")
+
+	aerr := schemas.Validate(AuditEntityAccount, map[string]interface{}{"limit": nil, "code": "a", "status": "active"})
+	assert.Nil(t, aerr, "Valid data should not have violations")
+	aerr = schemas.Validate(AuditEntityAccount, map[string]interface{}{"limit": -1, "code": "ab", "status": "deleted"})
+	assert.Equal(t, []*SchemaViolation{
+		&SchemaViolation{Path: "/code", Message: "Value should match exactly one of the schemas, but matches 2"},
+		&SchemaViolation{Path: "/limit", Message: "Value should match any of the schemas"},
+		&SchemaViolation{Path: "/status", Message: "Value should not match the schema"},
+	}, aerr.ErrorDetails().(map[string]interface{})["violations"], "Invalid violations")
+}
@@ -3,8 +3,6 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
-	"errors"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -16,6 +14,10 @@ var (
 	SearchNamespaceAccounts = "accounts"
 	// SearchNamespaceTransactions holds search namespace of transactions
 	SearchNamespaceTransactions = "transactions"
+	// SearchNamespaceAudit holds search namespace of the audit log
+	SearchNamespaceAudit = "audit"
+	// SearchNamespaceLines holds search namespace of the transaction lines
+	SearchNamespaceLines = "lines"
 	// SortDescByTime option sorts search items in descending order of time
 	SortDescByTime = "desc"
 	// SortAscByTime option sorts search items in ascending order of time
@@ -26,6 +28,7 @@ var (
 type SearchEngine struct {
 	db        *sql.DB
 	namespace string
+	accounts  *AccountFilter
 }
 
 // TransactionResult represents the response format of transactions
@@ -33,6 +36,7 @@ type TransactionResult struct {
 	ID        string                   `json:"id"`
 	Timestamp string                   `json:"timestamp"`
 	Data      json.RawMessage          `json:"data"`
+	Version   int64                    `json:"version"`
 	Lines     []*TransactionLineResult `json:"lines"`
 }
 
@@ -47,23 +51,136 @@ type AccountResult struct {
 	ID      string          `json:"id"`
 	Balance int             `json:"balance"`
 	Data    json.RawMessage `json:"data"`
+	Version int64           `json:"version"`
+}
+
+// LineResult represents the response format of the transaction lines along with their transactions and accounts
+type LineResult struct {
+	ID              int64           `json:"id"`
+	TransactionID   string          `json:"transaction_id"`
+	AccountID       string          `json:"account"`
+	Delta           int             `json:"delta"`
+	Timestamp       string          `json:"timestamp"`
+	TransactionData json.RawMessage `json:"transaction_data"`
+	AccountData     json.RawMessage `json:"account_data"`
+}
+
+const (
+	// accountsSelectSQL selects the accounts in the format of `AccountResult`
+	accountsSelectSQL = "SELECT id, balance, data, version FROM current_balances"
+	// accountsAsOfSelectSQL selects the accounts in the format of `AccountResult` with the balances
+	// computed only from the lines of transactions made on or before the timestamp placeholder
+	accountsAsOfSelectSQL = `SELECT id, balance, data, version FROM (
+				SELECT accounts.id, accounts.data, COALESCE(SUM(lines.delta), 0) AS balance, accounts.version
+					FROM accounts LEFT OUTER JOIN (
+						lines JOIN transactions
+						ON (transactions.id = lines.transaction_id AND transactions.timestamp <= ?)
+					) ON (accounts.id = lines.account_id)
+					GROUP BY accounts.id
+			) AS current_balances`
+	// transactionsSelectSQL selects the transactions in the format of `TransactionResult`
+	transactionsSelectSQL = `SELECT id, timestamp, data, version,
+					array_to_json(ARRAY(
+						SELECT lines.account_id FROM lines
+							WHERE transaction_id=transactions.id
+							ORDER BY lines.account_id
+					)) AS account_array,
+					array_to_json(ARRAY(
+						SELECT lines.delta FROM lines
+							WHERE transaction_id=transactions.id
+							ORDER BY lines.account_id
+					)) AS delta_array
+			FROM transactions`
+	// linesSelectSQL selects the lines in the format of `LineResult`.
+	// The search terms and ranges apply on the data of the transactions and accounts by the first key of their paths.
+	linesSelectSQL = `SELECT id, transaction_id, account, delta, timestamp, transaction_data, account_data FROM (
+				SELECT lines.id, lines.transaction_id, lines.account_id AS account, lines.delta,
+						transactions.timestamp, transactions.data AS transaction_data, accounts.data AS account_data
+					FROM lines
+						JOIN transactions ON (transactions.id = lines.transaction_id)
+						JOIN accounts ON (accounts.id = lines.account_id)
+			) AS lines`
+)
+
+// rowScanner is implemented by both `sql.Row` and `sql.Rows`
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccountResult(row rowScanner) (*AccountResult, error) {
+	acc := &AccountResult{}
+	if err := row.Scan(&acc.ID, &acc.Balance, &acc.Data, &acc.Version); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func scanTransactionResult(row rowScanner) (*TransactionResult, error) {
+	txn := &TransactionResult{}
+	var rawAccounts, rawDelta string
+	if err := row.Scan(&txn.ID, &txn.Timestamp, &txn.Data, &txn.Version, &rawAccounts, &rawDelta); err != nil {
+		return nil, err
+	}
+
+	var accounts []string
+	var delta []int
+	json.Unmarshal([]byte(rawAccounts), &accounts)
+	json.Unmarshal([]byte(rawDelta), &delta)
+	var lines []*TransactionLineResult
+	for i, acc := range accounts {
+		l := &TransactionLineResult{}
+		l.AccountID = acc
+		l.Delta = delta[i]
+		lines = append(lines, l)
+	}
+	txn.Lines = lines
+	return txn, nil
+}
+
+func scanLineResult(row rowScanner) (*LineResult, error) {
+	line := &LineResult{}
+	err := row.Scan(&line.ID, &line.TransactionID, &line.AccountID, &line.Delta, &line.Timestamp,
+		&line.TransactionData, &line.AccountData)
+	if err != nil {
+		return nil, err
+	}
+	return line, nil
 }
 
 // NewSearchEngine returns a new instance of `SearchEngine`
 func NewSearchEngine(db *sql.DB, namespace string) (*SearchEngine, ledgerError.ApplicationError) {
-	if namespace != SearchNamespaceAccounts && namespace != SearchNamespaceTransactions {
+	switch namespace {
+	case SearchNamespaceAccounts, SearchNamespaceTransactions, SearchNamespaceAudit, SearchNamespaceLines:
+	default:
 		return nil, SearchNamespaceInvalidError(namespace)
 	}
 
 	return &SearchEngine{db: db, namespace: namespace}, nil
 }
 
+// RestrictAccounts restricts the search results to the accounts matching the filter.
+// The transactions are restricted to the ones having lines of the matching accounts,
+// and the lines, including the ones of the transactions, are restricted to the ones of the matching accounts.
+func (engine *SearchEngine) RestrictAccounts(accounts *AccountFilter) {
+	engine.accounts = accounts
+}
+
 // Query returns the results of a searc query
 func (engine *SearchEngine) Query(q string) (interface{}, ledgerError.ApplicationError) {
 	rawQuery, aerr := NewSearchRawQuery(q)
 	if aerr != nil {
 		return nil, aerr
 	}
+	if aerr := rawQuery.validateFields(engine.namespace); aerr != nil {
+		return nil, aerr
+	}
+	if aerr := rawQuery.validateDataPaths(engine.namespace); aerr != nil {
+		return nil, aerr
+	}
+	if aerr := rawQuery.validateRanges(); aerr != nil {
+		return nil, aerr
+	}
+	rawQuery.accounts = engine.accounts.Patterns()
 
 	sqlQuery := rawQuery.ToSQLQuery(engine.namespace)
 	rows, err := engine.db.Query(sqlQuery.sql, sqlQuery.args...)
@@ -76,8 +193,8 @@ func (engine *SearchEngine) Query(q string) (interface{}, ledgerError.Applicatio
 	case SearchNamespaceAccounts:
 		accounts := make([]*AccountResult, 0)
 		for rows.Next() {
-			acc := &AccountResult{}
-			if err := rows.Scan(&acc.ID, &acc.Balance, &acc.Data); err != nil {
+			acc, err := scanAccountResult(rows)
+			if err != nil {
 				return nil, DBError(err)
 			}
 			accounts = append(accounts, acc)
@@ -87,48 +204,72 @@ func (engine *SearchEngine) Query(q string) (interface{}, ledgerError.Applicatio
 	case SearchNamespaceTransactions:
 		transactions := make([]*TransactionResult, 0)
 		for rows.Next() {
-			txn := &TransactionResult{}
-			var rawAccounts, rawDelta string
-			if err := rows.Scan(&txn.ID, &txn.Timestamp, &txn.Data, &rawAccounts, &rawDelta); err != nil {
+			txn, err := scanTransactionResult(rows)
+			if err != nil {
 				return nil, DBError(err)
 			}
-
-			var accounts []string
-			var delta []int
-			json.Unmarshal([]byte(rawAccounts), &accounts)
-			json.Unmarshal([]byte(rawDelta), &delta)
-			var lines []*TransactionLineResult
-			for i, acc := range accounts {
-				l := &TransactionLineResult{}
-				l.AccountID = acc
-				l.Delta = delta[i]
-				lines = append(lines, l)
-			}
-			txn.Lines = lines
+			txn.Lines = engine.accounts.FilterLines(txn.Lines)
 			transactions = append(transactions, txn)
 		}
 		return transactions, nil
+
+	case SearchNamespaceAudit:
+		entries := make([]*AuditEntry, 0)
+		for rows.Next() {
+			entry, err := scanAuditEntry(rows)
+			if err != nil {
+				return nil, DBError(err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+
+	case SearchNamespaceLines:
+		lines := make([]*LineResult, 0)
+		for rows.Next() {
+			line, err := scanLineResult(rows)
+			if err != nil {
+				return nil, DBError(err)
+			}
+			lines = append(lines, line)
+		}
+		return lines, nil
 	default:
 		return nil, SearchNamespaceInvalidError(engine.namespace)
 	}
 }
 
-// QueryContainer represents the format of query subsection inside `must` or `should`
+// QueryContainer represents the format of query subsection inside `must`, `should` or `must_not`
 type QueryContainer struct {
 	Fields     []map[string]map[string]interface{} `json:"fields"`
 	Terms      []map[string]interface{}            `json:"terms"`
 	RangeItems []map[string]map[string]interface{} `json:"ranges"`
+	// Lines are the conditions on the lines of the transactions, which are met by any of their lines
+	Lines []map[string]map[string]interface{} `json:"lines"`
+	// Exists and Missing are the keys present and absent in the data, regardless of their values
+	Exists  []string `json:"exists"`
+	Missing []string `json:"missing"`
+	// Bools are the nested bool queries, which are the query items like the others
+	Bools []BoolQuery `json:"bool"`
+}
+
+// BoolQuery represents the format of the bool query combining the clauses
+type BoolQuery struct {
+	MustClause    QueryContainer `json:"must"`
+	ShouldClause  QueryContainer `json:"should"`
+	MustNotClause QueryContainer `json:"must_not"`
 }
 
 // SearchRawQuery represents the format of search query
 type SearchRawQuery struct {
-	Offset   int    `json:"from,omitempty"`
-	Limit    int    `json:"size,omitempty"`
-	SortTime string `json:"sort_time,omitempty"`
-	Query    struct {
-		MustClause   QueryContainer `json:"must"`
-		ShouldClause QueryContainer `json:"should"`
-	} `json:"query"`
+	Offset   int       `json:"from,omitempty"`
+	Limit    int       `json:"size,omitempty"`
+	SortTime string    `json:"sort_time,omitempty"`
+	AsOf     string    `json:"as_of,omitempty"`
+	Query    BoolQuery `json:"query"`
+
+	// accounts is the mandatory restriction of the search, which is not part of the query
+	accounts AccountPatterns
 }
 
 // SearchSQLQuery hold information of search SQL query
@@ -137,32 +278,6 @@ type SearchSQLQuery struct {
 	args []interface{}
 }
 
-func hasValidKeys(items interface{}) bool {
-	var validKey = regexp.MustCompile(`^[a-z_A-Z]+$`)
-	switch t := items.(type) {
-	case []map[string]interface{}:
-		for _, item := range t {
-			for key := range item {
-				if !validKey.MatchString(key) {
-					return false
-				}
-			}
-		}
-		return true
-	case []map[string]map[string]interface{}:
-		for _, item := range t {
-			for key := range item {
-				if !validKey.MatchString(key) {
-					return false
-				}
-			}
-		}
-		return true
-	default:
-		return false
-	}
-}
-
 // NewSearchRawQuery returns a new instance of `SearchRawQuery`
 func NewSearchRawQuery(q string) (*SearchRawQuery, ledgerError.ApplicationError) {
 	var rawQuery *SearchRawQuery
@@ -171,19 +286,18 @@ func NewSearchRawQuery(q string) (*SearchRawQuery, ledgerError.ApplicationError)
 		return nil, SearchQueryInvalidError(err)
 	}
 
-	checkList := []interface{}{
-		rawQuery.Query.MustClause.Fields,
-		rawQuery.Query.MustClause.Terms,
-		rawQuery.Query.MustClause.RangeItems,
-		rawQuery.Query.ShouldClause.Fields,
-		rawQuery.Query.MustClause.Terms,
-		rawQuery.Query.MustClause.RangeItems,
-	}
-	for _, item := range checkList {
-		if !hasValidKeys(item) {
-			return nil, SearchQueryInvalidError(errors.New("Invalid key(s) in search query"))
+	// The terms, ranges, exists and missing have the dotted paths of the values in the data,
+	// while the fields and lines are validated against the registries of the namespace while running the query
+	for _, clause := range rawQuery.Query.containers() {
+		for _, key := range clause.dataKeys() {
+			if _, err := parseDataPath(key); err != nil {
+				return nil, SearchQueryInvalidError(err)
+			}
 		}
 	}
+	if aerr := ValidateTimestamp(rawQuery.AsOf); aerr != nil {
+		return nil, SearchQueryInvalidError(aerr)
+	}
 	return rawQuery, nil
 }
 
@@ -194,79 +308,63 @@ func (rawQuery *SearchRawQuery) ToSQLQuery(namespace string) *SearchSQLQuery {
 
 	switch namespace {
 	case SearchNamespaceAccounts:
-		q = "SELECT id, balance, data FROM current_balances"
+		q = accountsSelectSQL
+		if rawQuery.AsOf != "" {
+			q = accountsAsOfSelectSQL
+			args = append(args, rawQuery.AsOf)
+		}
 	case SearchNamespaceTransactions:
-		q = `SELECT id, timestamp, data,
-					array_to_json(ARRAY(
-						SELECT lines.account_id FROM lines
-							WHERE transaction_id=transactions.id
-							ORDER BY lines.account_id
-					)) AS account_array,
-					array_to_json(ARRAY(
-						SELECT lines.delta FROM lines
-							WHERE transaction_id=transactions.id
-							ORDER BY lines.account_id
-					)) AS delta_array
-			FROM transactions`
+		q = transactionsSelectSQL
+	case SearchNamespaceAudit:
+		q = auditSelectSQL
+	case SearchNamespaceLines:
+		q = linesSelectSQL
 	default:
 		return nil
 	}
 
 	// Process must queries
 	var mustWhere []string
-	mustClause := rawQuery.Query.MustClause
-	fieldsWhere, fieldsArgs := convertFieldsToSQL(mustClause.Fields)
-	mustWhere = append(mustWhere, fieldsWhere...)
-	args = append(args, fieldsArgs...)
-
-	termsWhere, termsArgs := convertTermsToSQL(mustClause.Terms)
-	mustWhere = append(mustWhere, termsWhere...)
-	args = append(args, termsArgs...)
-
-	rangesWhere, rangesArgs := convertRangesToSQL(mustClause.RangeItems)
-	mustWhere = append(mustWhere, rangesWhere...)
-	args = append(args, rangesArgs...)
-
-	// Process should queries
-	var shouldWhere []string
-	shouldClause := rawQuery.Query.ShouldClause
-	fieldsWhere, fieldsArgs = convertFieldsToSQL(shouldClause.Fields)
-	shouldWhere = append(shouldWhere, fieldsWhere...)
-	args = append(args, fieldsArgs...)
-
-	termsWhere, termsArgs = convertTermsToSQL(shouldClause.Terms)
-	shouldWhere = append(shouldWhere, termsWhere...)
-	args = append(args, termsArgs...)
-
-	rangesWhere, rangesArgs = convertRangesToSQL(shouldClause.RangeItems)
-	shouldWhere = append(shouldWhere, rangesWhere...)
-	args = append(args, rangesArgs...)
+	if rawQuery.accounts.IsRestricted() {
+		column := "id"
+		switch namespace {
+		case SearchNamespaceTransactions:
+			column = "lines.account_id"
+		case SearchNamespaceLines:
+			column = "account"
+		}
+		accountsWhere, accountsArgs := rawQuery.accounts.toSQL(column)
+		if namespace == SearchNamespaceTransactions {
+			accountsWhere = "id IN (SELECT lines.transaction_id FROM lines WHERE " + accountsWhere + ")"
+		}
+		mustWhere = append(mustWhere, accountsWhere)
+		args = append(args, accountsArgs...)
+	}
+	where, whereArgs := rawQuery.Query.toSQL(namespace, mustWhere)
+	args = append(args, whereArgs...)
 
 	var offset = rawQuery.Offset
 	var limit = rawQuery.Limit
 
-	if len(mustWhere) == 0 && len(shouldWhere) == 0 {
-		return &SearchSQLQuery{sql: q, args: args}
-	}
-
-	q += " WHERE "
-	if len(mustWhere) != 0 {
-		q += "(" + strings.Join(mustWhere, " AND ") + ")"
-		if len(shouldWhere) != 0 {
-			q += " AND "
-		}
-	}
-
-	if len(shouldWhere) != 0 {
-		q += "(" + strings.Join(shouldWhere, " OR ") + ")"
+	// The sorting and pagination apply even without any conditions
+	if where != "" {
+		q += " WHERE " + where
 	}
 
-	if namespace == SearchNamespaceTransactions {
+	switch namespace {
+	case SearchNamespaceTransactions:
 		if rawQuery.SortTime == SortDescByTime {
 			q += " ORDER BY timestamp DESC"
 		} else {
 			q += " ORDER BY timestamp"
 		}
+	case SearchNamespaceAudit, SearchNamespaceLines:
+		// The entries and lines made in the same DB transaction share the timestamp
+		if rawQuery.SortTime == SortDescByTime {
+			q += " ORDER BY timestamp DESC, id DESC"
+		} else {
+			q += " ORDER BY timestamp, id"
+		}
 	}
 
 	if offset > 0 {
@@ -279,3 +377,90 @@ func (rawQuery *SearchRawQuery) ToSQLQuery(namespace string) *SearchSQLQuery {
 	q = enumerateSQLPlacholder(q)
 	return &SearchSQLQuery{sql: q, args: args}
 }
+
+// containers returns the clauses of the bool query along with the clauses of its nested bool queries
+func (query *BoolQuery) containers() []*QueryContainer {
+	clauses := []*QueryContainer{&query.MustClause, &query.ShouldClause, &query.MustNotClause}
+	containers := clauses
+	for _, clause := range clauses {
+		for i := range clause.Bools {
+			containers = append(containers, clause.Bools[i].containers()...)
+		}
+	}
+	return containers
+}
+
+// toSQL returns the conditions of the bool query along with the `must` conditions
+// which are not part of the query. All of `must`, any of `should` and none of `must_not` are to be satisfied.
+func (query *BoolQuery) toSQL(namespace string, mustWhere []string) (where string, args []interface{}) {
+	mustWhere, mustArgs := query.MustClause.toSQL(namespace, mustWhere)
+	shouldWhere, shouldArgs := query.ShouldClause.toSQL(namespace, nil)
+	mustNotWhere, mustNotArgs := query.MustNotClause.toSQL(namespace, nil)
+	args = append(append(mustArgs, shouldArgs...), mustNotArgs...)
+
+	var clauses []string
+	if len(mustWhere) != 0 {
+		clauses = append(clauses, "("+strings.Join(mustWhere, " AND ")+")")
+	}
+	if len(shouldWhere) != 0 {
+		clauses = append(clauses, "("+strings.Join(shouldWhere, " OR ")+")")
+	}
+	// The conditions on the missing values in the data are null, which don't exclude the items
+	if len(mustNotWhere) != 0 {
+		clauses = append(clauses, "NOT COALESCE(("+strings.Join(mustNotWhere, " OR ")+"), FALSE)")
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// dataKeys returns the dotted paths of the values in the data, which are the keys of the terms, ranges, exists and missing
+func (clause *QueryContainer) dataKeys() []string {
+	var keys []string
+	for _, term := range clause.Terms {
+		for key := range term {
+			keys = append(keys, key)
+		}
+	}
+	for _, rangeItem := range clause.RangeItems {
+		for key := range rangeItem {
+			keys = append(keys, key)
+		}
+	}
+	return append(append(keys, clause.Exists...), clause.Missing...)
+}
+
+// toSQL returns the conditions of the query items in the clause appended to the given conditions
+func (clause *QueryContainer) toSQL(namespace string, where []string) ([]string, []interface{}) {
+	fieldsWhere, args := convertFieldsToSQL(namespace, clause.Fields)
+	where = append(where, fieldsWhere...)
+
+	linesWhere, linesArgs := convertLinesToSQL(namespace, clause.Lines)
+	where = append(where, linesWhere...)
+	args = append(args, linesArgs...)
+
+	termsWhere, termsArgs := convertTermsToSQL(namespace, clause.Terms)
+	where = append(where, termsWhere...)
+	args = append(args, termsArgs...)
+
+	rangesWhere, rangesArgs := convertRangesToSQL(namespace, clause.RangeItems)
+	where = append(where, rangesWhere...)
+	args = append(args, rangesArgs...)
+
+	existsWhere, existsArgs := convertExistsToSQL(namespace, clause.Exists, false)
+	where = append(where, existsWhere...)
+	args = append(args, existsArgs...)
+
+	missingWhere, missingArgs := convertExistsToSQL(namespace, clause.Missing, true)
+	where = append(where, missingWhere...)
+	args = append(args, missingArgs...)
+
+	for i := range clause.Bools {
+		boolWhere, boolArgs := clause.Bools[i].toSQL(namespace, nil)
+		// The empty bool query matches all the items
+		if boolWhere == "" {
+			boolWhere = "TRUE"
+		}
+		where = append(where, "("+boolWhere+")")
+		args = append(args, boolArgs...)
+	}
+	return where, args
+}
@@ -0,0 +1,56 @@
+package models
+
+import "github.com/stretchr/testify/assert"
+
+func (ss *SearchSuite) TestSearchAccountsAsOf() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "accounts")
+
+	query := `{
+        "as_of": "2000-01-01 00:00:00.000",
+        "query": {
+            "must": {
+                "fields": [
+                    {"id": {"eq": "acc1"}}
+                ]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	accounts, _ := results.([]*AccountResult)
+	assert.Equal(t, 1, len(accounts), "Accounts count doesn't match")
+	assert.Equal(t, 0, accounts[0].Balance, "Account balance as of the past doesn't match")
+
+	query = `{
+        "as_of": "2100-01-01 00:00:00.000",
+        "query": {
+            "must": {
+                "fields": [
+                    {"id": {"eq": "acc1"}}
+                ]
+            }
+        }
+    }`
+	results, err = engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	accounts, _ = results.([]*AccountResult)
+	assert.Equal(t, 1, len(accounts), "Accounts count doesn't match")
+	assert.Equal(t, 1500, accounts[0].Balance, "Account balance as of the future doesn't match")
+
+	query = `{"as_of": "2100-01-01"}`
+	_, err = engine.Query(query)
+	assert.Equal(t, "search.query.invalid", err.ErrorCode(), "Invalid as_of timestamp should not be allowed")
+}
+
+func (ss *SearchSuite) TestGetAccountAsOf() {
+	t := ss.T()
+
+	account, err := ss.accDB.GetResultByID("acc2", "2000-01-01 00:00:00.000")
+	assert.Equal(t, nil, err, "Error while getting account")
+	assert.Equal(t, 0, account.Balance, "Account balance as of the past doesn't match")
+
+	account, err = ss.accDB.GetResultByID("acc2", "")
+	assert.Equal(t, nil, err, "Error while getting account")
+	assert.Equal(t, -1500, account.Balance, "Account balance doesn't match")
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoolQueryToSQL(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"query": {
+		"must": {
+			"fields": [{"id": {"like": "txn%"}}],
+			"bool": [{"must_not": {"terms": [{"status": "refunded"}, {"status": "disputed"}]}}]
+		},
+		"should": {
+			"bool": [
+				{"must": {"terms": [{"action": "sale"}, {"channel": "web"}]}},
+				{"must": {"terms": [{"action": "refund"}]}, "should": {"terms": [{"channel": "store"}]}},
+				{}
+			]
+		}
+	}}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceTransactions)
+	assert.Contains(t, sqlQuery.sql, " WHERE "+
+		"((id LIKE $1) AND (NOT COALESCE(((data #> $2::text[] @> $3::jsonb) OR (data #> $4::text[] @> $5::jsonb)), FALSE))) AND "+
+		"((((data #> $6::text[] @> $7::jsonb) AND (data #> $8::text[] @> $9::jsonb))) OR "+
+		"(((data #> $10::text[] @> $11::jsonb)) AND ((data #> $12::text[] @> $13::jsonb))) OR (TRUE)) "+
+		"ORDER BY", "Invalid SQL query")
+	assert.Equal(t, 13, len(sqlQuery.args), "Invalid SQL args")
+	assert.Equal(t, "txn%", sqlQuery.args[0], "Invalid SQL args")
+	assert.Equal(t, `"disputed"`, sqlQuery.args[4], "Invalid SQL args")
+	assert.Equal(t, `"store"`, sqlQuery.args[12], "Invalid SQL args")
+
+	// The keys in the nested queries are validated
+	_, aerr = NewSearchRawQuery(`{"query": {"must_not": {"bool": [{"should": {"terms": [{"a..b": 1}]}}]}}}`)
+	assert.Equal(t, "search.query.invalid", aerr.ErrorCode(), "Invalid error code")
+	rawQuery, _ = NewSearchRawQuery(`{"query": {"should": {"bool": [{"must_not": {"fields": [{"balance": {"gt": "1"}}]}}]}}}`)
+	aerr = rawQuery.validateFields(SearchNamespaceAccounts)
+	assert.Equal(t, "search.value.invalid", aerr.ErrorCode(), "Invalid error code")
+}
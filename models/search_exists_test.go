@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExistsQueryToSQL(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"query": {
+		"must": {"exists": ["settlement_id", "products.qw.coupons"]},
+		"should": {"missing": ["refund_id", "products.qw\\.v2"]}
+	}}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceTransactions)
+	assert.Contains(t, sqlQuery.sql, " WHERE "+
+		"((jsonb_exists(data, $1)) AND (COALESCE(jsonb_exists(data #> $2::text[], $3), FALSE))) AND "+
+		"((NOT jsonb_exists(data, $4)) OR (NOT COALESCE(jsonb_exists(data #> $5::text[], $6), FALSE))) ", "Invalid SQL query")
+	assert.Equal(t, []interface{}{
+		"settlement_id", pq.Array([]string{"products", "qw"}), "coupons", "refund_id", pq.Array([]string{"products"}), "qw.v2",
+	}, sqlQuery.args, "Invalid SQL args")
+
+	_, aerr = NewSearchRawQuery(`{"query": {"must_not": {"missing": ["products."]}}}`)
+	assert.Equal(t, "search.query.invalid", aerr.ErrorCode(), "Invalid error code")
+}
+
+func (ss *SearchSuite) TestSearchTransactionsWithExistsAndMissing() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "transactions")
+
+	query := `{
+        "query": {
+            "must": {
+                "exists": ["products.qw.tax"],
+                "missing": ["products.qw.coupons"]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ := results.([]*TransactionResult)
+	assert.Equal(t, 1, len(transactions), "Transactions count doesn't match")
+	assert.Equal(t, "txn2", transactions[0].ID, "Transaction ID doesn't match")
+
+	query = `{
+        "query": {
+            "should": {
+                "missing": ["products"]
+            },
+            "must_not": {
+                "exists": ["settlement_id"]
+            }
+        }
+    }`
+	results, err = engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ = results.([]*TransactionResult)
+	assert.Equal(t, 1, len(transactions), "Transactions count doesn't match")
+	assert.Equal(t, "txn3", transactions[0].ID, "Transaction ID doesn't match")
+}
+
+func (ss *SearchSuite) TestSearchAccountsWithExists() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "accounts")
+
+	query := `{
+        "query": {
+            "must": {
+                "exists": ["customer_id", "status"]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	accounts, _ := results.([]*AccountResult)
+	assert.Equal(t, 2, len(accounts), "Accounts count doesn't match")
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinesQueryToSQL(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"query": {
+		"must": {"lines": [{"account": {"like": "alice%"}, "delta": {"lt": 0}}]},
+		"must_not": {"lines": [{"account": {"eq": "bob"}}]}
+	}}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+	assert.Nil(t, rawQuery.validateFields(SearchNamespaceTransactions), "Lines should be valid")
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceTransactions)
+	assert.Contains(t, sqlQuery.sql, "id IN (SELECT lines.transaction_id FROM lines WHERE lines.", "Invalid SQL query")
+	assert.Contains(t, sqlQuery.sql, "NOT COALESCE(((id IN (SELECT lines.transaction_id FROM lines WHERE lines.account_id = $3))), FALSE)", "Invalid SQL query")
+	assert.Equal(t, 3, len(sqlQuery.args), "Invalid SQL args")
+	assert.Contains(t, sqlQuery.args[:2], int64(0), "Value should be coerced")
+
+	invalidQueries := []struct {
+		namespace string
+		query     string
+		code      string
+	}{
+		{SearchNamespaceAccounts, `{"query": {"must": {"lines": [{"account": {"eq": "alice"}}]}}}`, "search.field.invalid"},
+		{SearchNamespaceTransactions, `{"query": {"should": {"lines": [{"account_id": {"eq": "alice"}}]}}}`, "search.field.invalid"},
+		{SearchNamespaceTransactions, `{"query": {"must": {"lines": [{"delta": {"like": "1%"}}]}}}`, "search.operator.invalid"},
+		{SearchNamespaceTransactions, `{"query": {"must": {"lines": [{"delta": {"lt": "-1"}}]}}}`, "search.value.invalid"},
+	}
+	for _, invalid := range invalidQueries {
+		rawQuery, aerr = NewSearchRawQuery(invalid.query)
+		assert.Nil(t, aerr, "Error while parsing search query")
+		aerr = rawQuery.validateFields(invalid.namespace)
+		assert.Equal(t, invalid.code, aerr.ErrorCode(), "Invalid error code: "+invalid.query)
+	}
+	rawQuery, _ = NewSearchRawQuery(`{"query": {"must": {"lines": [{"account_id": {"eq": "alice"}}]}}}`)
+	aerr = rawQuery.validateFields(SearchNamespaceTransactions)
+	assert.Equal(t, "lines.account_id", aerr.ErrorDetails().(map[string]interface{})["field"], "Invalid field")
+}
+
+func (ss *SearchSuite) TestSearchTransactionsWithLines() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "transactions")
+
+	query := `{
+        "query": {
+            "must": {
+                "lines": [
+                    {"account": {"eq": "acc2"}, "delta": {"lte": -400}}
+                ]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ := results.([]*TransactionResult)
+	assert.Equal(t, 2, len(transactions), "Transactions count doesn't match")
+	assert.Equal(t, "txn1", transactions[0].ID, "Transaction ID doesn't match")
+	assert.Equal(t, "txn3", transactions[1].ID, "Transaction ID doesn't match")
+
+	// The conditions of a line are met by the same line
+	query = `{
+        "query": {
+            "must": {
+                "lines": [
+                    {"account": {"like": "acc1%"}, "delta": {"lt": 0}}
+                ]
+            }
+        }
+    }`
+	results, err = engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ = results.([]*TransactionResult)
+	assert.Equal(t, 0, len(transactions), "No transaction should exist for given query")
+}
@@ -0,0 +1,85 @@
+package models
+
+import "github.com/stretchr/testify/assert"
+
+func (ss *SearchSuite) TestSearchAccountsWithMustNot() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "accounts")
+
+	query := `{
+        "query": {
+            "must_not": {
+                "terms": [
+                    {"status": "inactive"}
+                ]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	accounts, _ := results.([]*AccountResult)
+	assert.Equal(t, 1, len(accounts), "Accounts count doesn't match")
+	assert.Equal(t, "acc1", accounts[0].ID, "Account ID doesn't match")
+
+	query = `{
+        "query": {
+            "must_not": {
+                "fields": [
+                    {"id": {"eq": "acc1"}}
+                ],
+                "terms": [
+                    {"status": "inactive"}
+                ]
+            }
+        }
+    }`
+	results, err = engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	accounts, _ = results.([]*AccountResult)
+	assert.Equal(t, 0, len(accounts), "No account should exist for given query")
+}
+
+func (ss *SearchSuite) TestSearchTransactionsWithMustNot() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "transactions")
+
+	query := `{
+        "query": {
+            "must": {
+                "terms": [
+                    {"action": "setcredit"}
+                ]
+            },
+            "must_not": {
+                "terms": [
+                    {"months": ["jan"]}
+                ],
+                "ranges": [
+                    {"expiry": {"gte": "2018-01-30"}}
+                ]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ := results.([]*TransactionResult)
+	assert.Equal(t, 1, len(transactions), "Transactions count doesn't match")
+	assert.Equal(t, "txn2", transactions[0].ID, "Transaction ID doesn't match")
+
+	// The items missing the values in the data are not excluded
+	query = `{
+        "query": {
+            "must_not": {
+                "ranges": [
+                    {"products.qw.tax": {"gt": 15}}
+                ]
+            }
+        }
+    }`
+	results, err = engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ = results.([]*TransactionResult)
+	assert.Equal(t, 2, len(transactions), "Transactions count doesn't match")
+	assert.Equal(t, "txn1", transactions[0].ID, "Transaction ID doesn't match")
+	assert.Equal(t, "txn3", transactions[1].ID, "Transaction ID doesn't match")
+}
@@ -143,3 +143,23 @@ func (ss *SearchSuite) TestSearchAccountsWithInOperator() {
 	accounts, _ = results.([]*AccountResult)
 	assert.Equal(t, 1, len(accounts), "Accounts count doesn't match")
 }
+
+func (ss *SearchSuite) TestSearchTransactionsWithNestedMustRanges() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "transactions")
+
+	query := `{
+        "query": {
+            "must": {
+                "ranges": [
+                    {"products.qw.tax": {"gte": 15}}
+                ]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ := results.([]*TransactionResult)
+	assert.Equal(t, 1, len(transactions), "Transactions count doesn't match")
+	assert.Equal(t, "txn2", transactions[0].ID, "Transaction ID doesn't match")
+}
@@ -73,3 +73,23 @@ func (ss *SearchSuite) TestSearchTransactionsWithMustTerms() {
 	transactions, _ = results.([]*TransactionResult)
 	assert.Equal(t, 0, len(transactions), "No transaction should exist for given query")
 }
+
+func (ss *SearchSuite) TestSearchTransactionsWithNestedMustTerms() {
+	t := ss.T()
+	engine, _ := NewSearchEngine(ss.db, "transactions")
+
+	query := `{
+        "query": {
+            "must": {
+                "terms": [
+                    {"products.qw.coupons": ["x001"]}
+                ]
+            }
+        }
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	transactions, _ := results.([]*TransactionResult)
+	assert.Equal(t, 1, len(transactions), "Transactions count doesn't match")
+	assert.Equal(t, "txn1", transactions[0].ID, "Transaction ID doesn't match")
+}
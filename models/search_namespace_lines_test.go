@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinesNamespaceToSQL(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"query": {
+		"must": {
+			"fields": [{"delta": {"gt": 0}}],
+			"terms": [{"transaction.product": "qw"}],
+			"exists": ["account.type"]
+		},
+		"should": {"ranges": [{"account.limits.daily": {"lt": 1000}}]}
+	}}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+	assert.Nil(t, rawQuery.validateFields(SearchNamespaceLines), "Fields should be valid")
+	assert.Nil(t, rawQuery.validateDataPaths(SearchNamespaceLines), "Data paths should be valid")
+	rawQuery.accounts = AccountPatterns{"revenue.*"}
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceLines)
+	assert.Contains(t, sqlQuery.sql, ") AS lines WHERE "+
+		"((account LIKE $1) AND (delta > $2) AND (transaction_data #> $3::text[] @> $4::jsonb) AND (jsonb_exists(account_data, $5))) AND "+
+		"(((account_data #>> $6::text[])::float < $7)) ORDER BY timestamp, id", "Invalid SQL query")
+	assert.Equal(t, []interface{}{
+		"revenue.%", int64(0), pq.Array([]string{"product"}), `"qw"`, "type", pq.Array([]string{"limits", "daily"}), float64(1000),
+	}, sqlQuery.args, "Invalid SQL args")
+
+	// The paths in the data of the lines have the entity as the first key
+	for _, key := range []string{"product", "account", "transactions.product"} {
+		rawQuery, aerr = NewSearchRawQuery(`{"query": {"must": {"missing": ["` + key + `"]}}}`)
+		assert.Nil(t, aerr, "Error while parsing search query")
+		aerr = rawQuery.validateDataPaths(SearchNamespaceLines)
+		assert.Equal(t, "search.query.invalid", aerr.ErrorCode(), "Invalid error code: "+key)
+	}
+	rawQuery, _ = NewSearchRawQuery(`{"query": {"must": {"terms": [{"transaction.product": "qw"}]}}}`)
+	assert.Nil(t, rawQuery.validateDataPaths(SearchNamespaceTransactions), "Data paths should be valid")
+	rawQuery, _ = NewSearchRawQuery(`{"query": {"must": {"fields": [{"balance": {"gt": 0}}]}}}`)
+	assert.Equal(t, "search.field.invalid", rawQuery.validateFields(SearchNamespaceLines).ErrorCode(), "Invalid error code")
+}
+
+func (ss *SearchSuite) TestSearchLines() {
+	t := ss.T()
+	engine, aerr := NewSearchEngine(ss.db, "lines")
+	assert.Nil(t, aerr, "Error creating search engine")
+
+	query := `{
+        "query": {
+            "must": {
+                "fields": [
+                    {"delta": {"lt": 0}}
+                ],
+                "ranges": [
+                    {"transaction.products.qw.tax": {"gte": 14}}
+                ],
+                "terms": [
+                    {"account.status": "inactive"}
+                ]
+            }
+        },
+        "sort_time": "desc"
+    }`
+	results, err := engine.Query(query)
+	assert.Equal(t, nil, err, "Error in building search query")
+	lines, _ := results.([]*LineResult)
+	assert.Equal(t, 2, len(lines), "Lines count doesn't match")
+	assert.Equal(t, "txn2", lines[0].TransactionID, "Transaction ID doesn't match")
+	assert.Equal(t, "txn1", lines[1].TransactionID, "Transaction ID doesn't match")
+	assert.Equal(t, "acc2", lines[1].AccountID, "Account ID doesn't match")
+	assert.Equal(t, -1000, lines[1].Delta, "Delta doesn't match")
+
+	// The lines are restricted to the lines of the accounts
+	engine.RestrictAccounts(NewAccountFilter(AccountPatterns{"acc1"}))
+	results, err = engine.Query(`{"query": {"must": {"terms": [{"transaction.action": "setcredit"}]}}}`)
+	assert.Equal(t, nil, err, "Error in building search query")
+	lines, _ = results.([]*LineResult)
+	assert.Equal(t, 3, len(lines), "Lines count doesn't match")
+	for _, line := range lines {
+		assert.Equal(t, "acc1", line.AccountID, "Account ID doesn't match")
+	}
+}
@@ -72,6 +72,9 @@ func (ss *SearchSuite) SetupSuite() {
 			"action": "setcredit",
 			"expiry": "2018-01-01",
 			"months": []string{"jan", "feb", "mar"},
+			"products": map[string]interface{}{
+				"qw": map[string]interface{}{"tax": 14.5, "coupons": []string{"x001"}},
+			},
 		},
 	}
 	ok := ss.txnDB.Transact(txn1)
@@ -92,6 +95,9 @@ func (ss *SearchSuite) SetupSuite() {
 			"action": "setcredit",
 			"expiry": "2018-01-15",
 			"months": []string{"apr", "may", "jun"},
+			"products": map[string]interface{}{
+				"qw": map[string]interface{}{"tax": 18},
+			},
 		},
 	}
 	ok = ss.txnDB.Transact(txn2)
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+const (
+	fieldTypeString    = "string"
+	fieldTypeInteger   = "integer"
+	fieldTypeTimestamp = "timestamp"
+)
+
+// searchFields is the registry of the columns of each search namespace which can be filtered
+// by the `fields` query, along with their types
+var searchFields = map[string]map[string]string{
+	SearchNamespaceAccounts: {
+		"id":      fieldTypeString,
+		"balance": fieldTypeInteger,
+		"version": fieldTypeInteger,
+	},
+	SearchNamespaceTransactions: {
+		"id":        fieldTypeString,
+		"timestamp": fieldTypeTimestamp,
+		"version":   fieldTypeInteger,
+	},
+	SearchNamespaceLines: {
+		"id":             fieldTypeInteger,
+		"transaction_id": fieldTypeString,
+		"account":        fieldTypeString,
+		"delta":          fieldTypeInteger,
+		"timestamp":      fieldTypeTimestamp,
+	},
+	SearchNamespaceAudit: {
+		"id":          fieldTypeInteger,
+		"timestamp":   fieldTypeTimestamp,
+		"identity":    fieldTypeString,
+		"request_id":  fieldTypeString,
+		"endpoint":    fieldTypeString,
+		"entity_type": fieldTypeString,
+		"entity_id":   fieldTypeString,
+		"action":      fieldTypeString,
+	},
+}
+
+// searchDataColumns are the columns of the data of the entities joined in the namespaces,
+// which are the first keys of the paths in the data. The other namespaces have the single column `data`.
+var searchDataColumns = map[string]map[string]string{
+	SearchNamespaceLines: {
+		"transaction": "transaction_data",
+		"account":     "account_data",
+	},
+}
+
+// searchLineFields is the registry of the columns of the transaction lines which can be filtered
+// by the `lines` query, along with their types
+var searchLineFields = map[string]string{
+	"account": fieldTypeString,
+	"delta":   fieldTypeInteger,
+}
+
+// searchLineColumns are the columns of the fields of the `lines` query
+var searchLineColumns = map[string]string{
+	"account": "lines.account_id",
+	"delta":   "lines.delta",
+}
+
+// searchFieldOperators are the operators allowed on the fields of each type
+var searchFieldOperators = map[string][]string{
+	fieldTypeString:    {"eq", "ne", "lt", "lte", "gt", "gte", "like", "notlike"},
+	fieldTypeInteger:   {"eq", "ne", "lt", "lte", "gt", "gte"},
+	fieldTypeTimestamp: {"eq", "ne", "lt", "lte", "gt", "gte"},
+}
+
+// searchTimestampLayouts are the layouts of the timestamps in the `fields` query.
+// The timestamps without time are the start of the day.
+var searchTimestampLayouts = []string{LedgerTimestampLayout, "2006-01-02 15:04:05", "2006-01-02"}
+
+// validateFields validates the fields of the `fields` and `lines` queries against the registries of the namespace,
+// and coerces their values to the types of the fields
+func (rawQuery *SearchRawQuery) validateFields(namespace string) ledgerError.ApplicationError {
+	for _, clause := range rawQuery.Query.containers() {
+		if aerr := validateFieldItems(namespace, searchFields[namespace], "", clause.Fields); aerr != nil {
+			return aerr
+		}
+		if len(clause.Lines) != 0 && namespace != SearchNamespaceTransactions {
+			return SearchFieldInvalidError(namespace, "lines")
+		}
+		if aerr := validateFieldItems(namespace, searchLineFields, "lines.", clause.Lines); aerr != nil {
+			return aerr
+		}
+	}
+	return nil
+}
+
+// validateFieldItems validates the query items against the registry of the fields,
+// whose names are prefixed in the errors
+func validateFieldItems(namespace string, registry map[string]string, prefix string, items []map[string]map[string]interface{}) ledgerError.ApplicationError {
+	for _, item := range items {
+		for key, comparison := range item {
+			fieldType, ok := registry[key]
+			if !ok {
+				return SearchFieldInvalidError(namespace, prefix+key)
+			}
+			for op, value := range comparison {
+				if !isFieldOperator(fieldType, op) {
+					return SearchOperatorInvalidError(prefix+key, op, searchFieldOperators[fieldType])
+				}
+				coerced, err := coerceFieldValue(fieldType, value)
+				if err != nil {
+					return SearchValueInvalidError(prefix+key, err)
+				}
+				comparison[op] = coerced
+			}
+		}
+	}
+	return nil
+}
+
+// validateDataPaths validates the paths in the data against the columns of the data in the namespace
+func (rawQuery *SearchRawQuery) validateDataPaths(namespace string) ledgerError.ApplicationError {
+	for _, clause := range rawQuery.Query.containers() {
+		for _, key := range clause.dataKeys() {
+			// The paths are parsed while parsing the query
+			path, _ := parseDataPath(key)
+			if _, _, ok := dataColumn(namespace, path); !ok {
+				var entities []string
+				for entity := range searchDataColumns[namespace] {
+					entities = append(entities, entity)
+				}
+				sort.Strings(entities)
+				return SearchQueryInvalidError(fmt.Errorf("Invalid key: %v (should be a path in the data of %v)", key, strings.Join(entities, ", ")))
+			}
+		}
+	}
+	return nil
+}
+
+// validateRanges validates the values of the `in` and `nin` operators of the `ranges` query, which should be non-empty lists
+func (rawQuery *SearchRawQuery) validateRanges() ledgerError.ApplicationError {
+	for _, clause := range rawQuery.Query.containers() {
+		for _, rangeItem := range clause.RangeItems {
+			for key, comparison := range rangeItem {
+				for _, op := range []string{"in", "nin"} {
+					value, ok := comparison[op]
+					if !ok {
+						continue
+					}
+					if values, ok := value.([]interface{}); !ok || len(values) == 0 {
+						return SearchValueInvalidError(key, fmt.Errorf("Value of %v should be a non-empty list: %v", op, jsonify(value)))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dataColumn returns the column of the data having the path, and the path of the value in it
+func dataColumn(namespace string, path []string) (column string, valuePath []string, ok bool) {
+	columns, joined := searchDataColumns[namespace]
+	if !joined {
+		return "data", path, true
+	}
+	if column, ok = columns[path[0]]; !ok || len(path) < 2 {
+		return "", nil, false
+	}
+	return column, path[1:], true
+}
+
+func isFieldOperator(fieldType string, op string) bool {
+	for _, allowed := range searchFieldOperators[fieldType] {
+		if op == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// coerceFieldValue returns the value of the JSON query in the type of the field
+func coerceFieldValue(fieldType string, value interface{}) (interface{}, error) {
+	switch fieldType {
+	case fieldTypeInteger:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return nil, fmt.Errorf("Value should be an integer: %v", jsonify(value))
+		}
+		return int64(number), nil
+	case fieldTypeTimestamp:
+		text, ok := value.(string)
+		if ok {
+			for _, layout := range searchTimestampLayouts {
+				if timestamp, err := time.Parse(layout, text); err == nil {
+					return timestamp.Format(LedgerTimestampLayout), nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("Value should be a timestamp in the format %v: %v", LedgerTimestampLayout, jsonify(value))
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("Value should be a string: %v", jsonify(value))
+		}
+		return text, nil
+	}
+}
+
+// fieldPlaceholder returns the placeholder of the value of the field, casted to the type of its column
+func fieldPlaceholder(namespace string, key string) string {
+	if searchFields[namespace][key] == fieldTypeTimestamp {
+		return "?::timestamp"
+	}
+	return "?"
+}
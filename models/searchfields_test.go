@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchFields(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"query": {"must": {"fields": [
+		{"balance": {"lt": 0}, "version": {"gte": 2}}
+	]}, "should": {"fields": [{"id": {"like": "ACME.%"}}]}}}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+	assert.Nil(t, rawQuery.validateFields(SearchNamespaceAccounts), "Fields should be valid")
+	assert.Equal(t, int64(0), rawQuery.Query.MustClause.Fields[0]["balance"]["lt"], "Value should be coerced")
+
+	rawQuery, _ = NewSearchRawQuery(`{"query": {"must": {"fields": [{"timestamp": {"gte": "2017-08-08"}}]}}}`)
+	assert.Nil(t, rawQuery.validateFields(SearchNamespaceTransactions), "Fields should be valid")
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceTransactions)
+	assert.Contains(t, sqlQuery.sql, "WHERE ((timestamp >= $1::timestamp))", "Invalid SQL query")
+	assert.Equal(t, []interface{}{"2017-08-08 00:00:00.000"}, sqlQuery.args, "Invalid SQL args")
+
+	invalidQueries := []struct {
+		namespace string
+		query     string
+		code      string
+	}{
+		{SearchNamespaceAccounts, `{"query": {"must": {"fields": [{"data": {"eq": "x"}}]}}}`, "search.field.invalid"},
+		{SearchNamespaceAccounts, `{"query": {"should": {"fields": [{"timestamp": {"gt": "2017-01-01"}}]}}}`, "search.field.invalid"},
+		{SearchNamespaceAccounts, `{"query": {"must": {"fields": [{"balance": {"like": "1%"}}]}}}`, "search.operator.invalid"},
+		{SearchNamespaceAccounts, `{"query": {"must": {"fields": [{"id": {"in": ["a"]}}]}}}`, "search.operator.invalid"},
+		{SearchNamespaceAccounts, `{"query": {"must": {"fields": [{"balance": {"gt": "zero"}}]}}}`, "search.value.invalid"},
+		{SearchNamespaceAccounts, `{"query": {"must": {"fields": [{"balance": {"gt": 1.5}}]}}}`, "search.value.invalid"},
+		{SearchNamespaceAccounts, `{"query": {"must": {"fields": [{"id": {"eq": 1}}]}}}`, "search.value.invalid"},
+		{SearchNamespaceTransactions, `{"query": {"must": {"fields": [{"timestamp": {"gt": "yesterday"}}]}}}`, "search.value.invalid"},
+		{SearchNamespaceAudit, `{"query": {"must": {"fields": [{"before": {"eq": "x"}}]}}}`, "search.field.invalid"},
+	}
+	for _, invalid := range invalidQueries {
+		rawQuery, aerr = NewSearchRawQuery(invalid.query)
+		assert.Nil(t, aerr, "Error while parsing search query")
+		aerr = rawQuery.validateFields(invalid.namespace)
+		assert.Equal(t, invalid.code, aerr.ErrorCode(), "Invalid error code: "+invalid.query)
+	}
+
+	// The fields which are not validated don't match any item
+	rawQuery, _ = NewSearchRawQuery(`{"query": {"must": {"fields": [{"1=1 OR id": {"eq": "x"}}]}}}`)
+	sqlQuery = rawQuery.ToSQLQuery(SearchNamespaceAccounts)
+	assert.Contains(t, sqlQuery.sql, "WHERE ((FALSE))", "Invalid SQL query")
+}
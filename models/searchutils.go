@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+
+	"github.com/lib/pq"
 )
 
 // replaces ? to corresponding placeholder index ($1, $2,...)
@@ -19,6 +21,46 @@ func enumerateSQLPlacholder(msql string) (psql string) {
 	return
 }
 
+// parseDataPath parses the dotted path of a value in the data, such as `products.qw.tax`.
+// The dots and backslashes in the keys are escaped by a backslash, such as `discount\.percent`.
+func parseDataPath(key string) ([]string, error) {
+	var path []string
+	var segment []rune
+	escaped := false
+	for _, c := range key {
+		switch {
+		case escaped:
+			if c != '.' && c != '\\' {
+				return nil, fmt.Errorf("Invalid escape in key: %v", key)
+			}
+			segment = append(segment, c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '.':
+			path = append(path, string(segment))
+			segment = nil
+		default:
+			segment = append(segment, c)
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("Invalid escape in key: %v", key)
+	}
+	path = append(path, string(segment))
+
+	// The top level keys follow the grammar of the data keys, while the nested keys can be any string
+	if !validDataKey.MatchString(path[0]) {
+		return nil, fmt.Errorf("Invalid key: %v", key)
+	}
+	for _, segment := range path[1:] {
+		if segment == "" {
+			return nil, fmt.Errorf("Invalid key: %v", key)
+		}
+	}
+	return path, nil
+}
+
 func jsonify(input interface{}) string {
 	j, _ := json.Marshal(input)
 	return string(j)
@@ -48,7 +90,7 @@ func sqlComparisonOp(op string) string {
 	return "="
 }
 
-func convertTermsToSQL(terms []map[string]interface{}) (where []string, args []interface{}) {
+func convertTermsToSQL(namespace string, terms []map[string]interface{}) (where []string, args []interface{}) {
 	// Sample terms
 	/*
 	   "terms": [
@@ -70,22 +112,66 @@ func convertTermsToSQL(terms []map[string]interface{}) (where []string, args []i
 	   SELECT id FROM transactions WHERE data->'colors' @> '["red", "green"]'::jsonb;
 	   -- object value
 	   SELECT id FROM transactions WHERE data->'products' @> '{"qw":{"coupons": ["x001"]}}'::jsonb;
+	   -- nested value with the key `products.qw.coupons`
+	   SELECT id FROM transactions WHERE data #> '{products,qw,coupons}' @> '["x001"]'::jsonb;
 	*/
 	for _, term := range terms {
 		var conditions []string
 		for key, value := range term {
-			conditions = append(
-				conditions,
-				fmt.Sprintf("data->'%s' @> ?::jsonb", key),
-			)
-			args = append(args, jsonify(value))
+			// The keys are validated while parsing and running the query
+			path, _ := parseDataPath(key)
+			column, path, ok := dataColumn(namespace, path)
+			if !ok {
+				conditions = append(conditions, "FALSE")
+				continue
+			}
+			conditions = append(conditions, column+" #> ?::text[] @> ?::jsonb")
+			args = append(args, pq.Array(path), jsonify(value))
 		}
 		where = append(where, "("+strings.Join(conditions, " AND ")+")")
 	}
 	return
 }
 
-func convertRangesToSQL(ranges []map[string]map[string]interface{}) (where []string, args []interface{}) {
+func convertExistsToSQL(namespace string, keys []string, missing bool) (where []string, args []interface{}) {
+	// Sample exists and missing
+	/*
+	   "exists": ["settlement_id", "products.qw"],
+	   "missing": ["refund_id"]
+	*/
+	// Corresponding SQL
+	/*
+	   SELECT id FROM transactions WHERE data ? 'settlement_id';
+	   -- nested key with the path `products.qw`
+	   SELECT id FROM transactions WHERE data #> '{products}' ? 'qw';
+	   SELECT id FROM transactions WHERE NOT data ? 'refund_id';
+	*/
+	// The `?` operator is written as its function `jsonb_exists`, as the `?` are the placeholders in the query.
+	// The `jsonb_path_ops` indexes of the data don't support the operator either way.
+	for _, key := range keys {
+		// The keys are validated while parsing and running the query
+		path, _ := parseDataPath(key)
+		column, path, ok := dataColumn(namespace, path)
+		var condn string
+		switch {
+		case !ok:
+			condn = "FALSE"
+		case len(path) == 1:
+			condn = "jsonb_exists(" + column + ", ?)"
+			args = append(args, path[0])
+		default:
+			condn = "COALESCE(jsonb_exists(" + column + " #> ?::text[], ?), FALSE)"
+			args = append(args, pq.Array(path[:len(path)-1]), path[len(path)-1])
+		}
+		if missing {
+			condn = "NOT " + condn
+		}
+		where = append(where, "("+condn+")")
+	}
+	return
+}
+
+func convertRangesToSQL(namespace string, ranges []map[string]map[string]interface{}) (where []string, args []interface{}) {
 	// Sample ranges
 	/*
 	   "ranges": [
@@ -99,12 +185,14 @@ func convertRangesToSQL(ranges []map[string]map[string]interface{}) (where []str
 	   SELECT id, data->'charge' FROM transactions WHERE (data->>'charge')::float >= 2000 AND (data->>'charge')::float <= 4000;
 	   -- other values
 	   SELECT id, data->'date' FROM transactions WHERE data->>'date' >= '2017-01-01' AND data->>'date' < '2017-06-31';
+	   -- nested value with the key `products.qw.tax`
+	   SELECT id FROM transactions WHERE (data #>> '{products,qw,tax}')::float >= 14;
 	*/
 	for _, rangeItem := range ranges {
 		var conditions []string
 		for key, comparison := range rangeItem {
 			for op, value := range comparison {
-				condn, arguments := getSQLConditionAndArgsFromRange(key, op, value)
+				condn, arguments := getSQLConditionAndArgsFromRange(namespace, key, op, value)
 				conditions = append(conditions, condn)
 				for _, arg := range arguments {
 					if arg != nil {
@@ -118,50 +206,64 @@ func convertRangesToSQL(ranges []map[string]map[string]interface{}) (where []str
 	return
 }
 
-func getSQLConditionAndArgsFromRange(key string, op string, value interface{}) (condition string, args []interface{}) {
-	getConditionAndArgs := func(key string, op string, val interface{}) (condn string, arg interface{}) {
+func getSQLConditionAndArgsFromRange(namespace string, key string, op string, value interface{}) (condition string, args []interface{}) {
+	// The keys are validated while parsing and running the query
+	path, _ := parseDataPath(key)
+	column, path, ok := dataColumn(namespace, path)
+	if !ok {
+		return "FALSE", nil
+	}
+	getConditionAndArgs := func(key string, op string, val interface{}) (condn string, args []interface{}) {
 		switch val.(type) {
 		case int, int8, int16, int32, int64, float32, float64:
-			condn = fmt.Sprintf("(data->>'%s')::float %s ?", key, sqlComparisonOp(op))
-			arg = val
+			condn = fmt.Sprintf("(%s #>> ?::text[])::float %s ?", column, sqlComparisonOp(op))
+			args = []interface{}{pq.Array(path), val}
 		case nil:
-			condn = fmt.Sprintf("data->>'%s' %s null", key, sqlComparisonOp(op))
-			arg = nil
+			condn = fmt.Sprintf("%s #>> ?::text[] %s null", column, sqlComparisonOp(op))
+			args = []interface{}{pq.Array(path)}
 		default:
-			condn = fmt.Sprintf("data->>'%s' %s ?", key, sqlComparisonOp(op))
-			arg = val
+			condn = fmt.Sprintf("%s #>> ?::text[] %s ?", column, sqlComparisonOp(op))
+			args = []interface{}{pq.Array(path), val}
 		}
 		return
 	}
 
 	switch op {
 	case "in", "nin":
-		// Convert IN, NOT IN condition to OR of EQ and NE conditions
-		var opnew string
-		if op == "in" {
-			opnew = "eq"
-		} else if op == "nin" {
-			opnew = "ne"
+		// Convert IN condition to OR of EQ conditions, and NOT IN condition to AND of NE conditions.
+		// The null values are compared using IS and IS NOT, as the other comparisons with null are never true.
+		opnew, nullOp, join := "eq", "is", " OR "
+		if op == "nin" {
+			opnew, nullOp, join = "ne", "isnot", " AND "
 		}
+		// The values are validated to be a non-empty list while running the query
 		values, _ := value.([]interface{})
-		for i, val := range values {
-			c, arg := getConditionAndArgs(key, opnew, val)
-			args = append(args, arg)
-			if i == 0 {
-				condition = c
-			} else {
-				condition = condition + " OR " + c
+		if len(values) == 0 {
+			if op == "nin" {
+				return "TRUE", nil
+			}
+			return "FALSE", nil
+		}
+		var conditions []string
+		for _, val := range values {
+			valueOp := opnew
+			if val == nil {
+				valueOp = nullOp
 			}
+			c, arguments := getConditionAndArgs(key, valueOp, val)
+			conditions = append(conditions, c)
+			args = append(args, arguments...)
 		}
+		condition = "(" + strings.Join(conditions, join) + ")"
 	default:
-		c, arg := getConditionAndArgs(key, op, value)
+		c, arguments := getConditionAndArgs(key, op, value)
 		condition = c
-		args = append(args, arg)
+		args = append(args, arguments...)
 	}
 	return
 }
 
-func convertFieldsToSQL(fields []map[string]map[string]interface{}) (where []string, args []interface{}) {
+func convertFieldsToSQL(namespace string, fields []map[string]map[string]interface{}) (where []string, args []interface{}) {
 	// Sample ranges
 	/*
 	   "fields": [
@@ -176,8 +278,14 @@ func convertFieldsToSQL(fields []map[string]map[string]interface{}) (where []str
 	for _, field := range fields {
 		var conditions []string
 		for key, comparison := range field {
+			// The fields are validated against the registry of the namespace while running the query,
+			// and the fields which are not validated don't match any item
+			if _, ok := searchFields[namespace][key]; !ok {
+				conditions = append(conditions, "FALSE")
+				continue
+			}
 			for op, value := range comparison {
-				condn := fmt.Sprintf("%s %s ?", key, sqlComparisonOp(op))
+				condn := fmt.Sprintf("%s %s %s", key, sqlComparisonOp(op), fieldPlaceholder(namespace, key))
 				conditions = append(conditions, condn)
 				args = append(args, value)
 			}
@@ -186,3 +294,43 @@ func convertFieldsToSQL(fields []map[string]map[string]interface{}) (where []str
 	}
 	return
 }
+
+func convertLinesToSQL(namespace string, lines []map[string]map[string]interface{}) (where []string, args []interface{}) {
+	// Sample lines
+	/*
+	   "lines": [
+	       {"account": {"like": "alice%"}, "delta": {"lt": 0}}
+	   ]
+	*/
+	// Corresponding SQL
+	/*
+	   SELECT id FROM transactions WHERE id IN (
+	       SELECT lines.transaction_id FROM lines WHERE lines.account_id LIKE 'alice%' AND lines.delta < 0
+	   );
+	*/
+	for _, line := range lines {
+		// The lines are validated while running the query, and only the transactions have lines
+		if namespace != SearchNamespaceTransactions {
+			where = append(where, "(FALSE)")
+			continue
+		}
+		var conditions []string
+		for key, comparison := range line {
+			column, ok := searchLineColumns[key]
+			if !ok {
+				conditions = append(conditions, "FALSE")
+				continue
+			}
+			for op, value := range comparison {
+				conditions = append(conditions, fmt.Sprintf("%s %s ?", column, sqlComparisonOp(op)))
+				args = append(args, value)
+			}
+		}
+		condn := "id IN (SELECT lines.transaction_id FROM lines"
+		if len(conditions) != 0 {
+			condn += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		where = append(where, "("+condn+"))")
+	}
+	return
+}
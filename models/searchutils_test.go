@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDataPath(t *testing.T) {
+	path, err := parseDataPath("products.qw.tax")
+	assert.Nil(t, err, "Error while parsing path")
+	assert.Equal(t, []string{"products", "qw", "tax"}, path, "Invalid path")
+	path, err = parseDataPath(`christmas-offer.discount\.percent.a\\b`)
+	assert.Nil(t, err, "Error while parsing path")
+	assert.Equal(t, []string{"christmas-offer", "discount.percent", `a\b`}, path, "Invalid path")
+	path, err = parseDataPath("months.0")
+	assert.Nil(t, err, "Error while parsing path")
+	assert.Equal(t, []string{"months", "0"}, path, "Invalid path")
+
+	for _, key := range []string{"", "1st", "status'", "products..tax", "products.", `products\q`, `products\`} {
+		_, err = parseDataPath(key)
+		assert.NotNil(t, err, "Path should be invalid: "+key)
+	}
+
+	_, aerr := NewSearchRawQuery(`{"query": {"should": {"ranges": [{"status'--": {"eq": 1}}]}}}`)
+	assert.Equal(t, "search.query.invalid", aerr.ErrorCode(), "Invalid error code")
+
+	where, args := convertRangesToSQL(SearchNamespaceTransactions, []map[string]map[string]interface{}{
+		{"products.qw.tax": {"in": []interface{}{14.5, nil}}},
+	})
+	assert.Equal(t, []string{"(((data #>> ?::text[])::float = ? OR data #>> ?::text[] IS null))"}, where, "Invalid SQL")
+	assert.Equal(t, []interface{}{pq.Array([]string{"products", "qw", "tax"}), 14.5, pq.Array([]string{"products", "qw", "tax"})}, args, "Invalid SQL args")
+}
+
+func TestRangeInToSQL(t *testing.T) {
+	rawQuery, aerr := NewSearchRawQuery(`{"query": {"must": {
+		"ranges": [{"action": {"in": ["intent", "invoice"]}}, {"amount": {"gt": 100}}, {"status": {"nin": ["charge", "refund", null]}}]
+	}}}`)
+	assert.Nil(t, aerr, "Error while parsing search query")
+	assert.Nil(t, rawQuery.validateRanges(), "Ranges should be valid")
+	sqlQuery := rawQuery.ToSQLQuery(SearchNamespaceTransactions)
+	assert.Contains(t, sqlQuery.sql, " WHERE "+
+		"(((data #>> $1::text[] = $2 OR data #>> $3::text[] = $4)) AND ((data #>> $5::text[])::float > $6) AND "+
+		"((data #>> $7::text[] != $8 AND data #>> $9::text[] != $10 AND data #>> $11::text[] IS NOT null))) "+
+		"ORDER BY", "Invalid SQL query")
+	assert.Equal(t, 11, len(sqlQuery.args), "Invalid SQL args")
+	assert.Equal(t, "invoice", sqlQuery.args[3], "Invalid SQL args")
+	assert.Equal(t, "refund", sqlQuery.args[9], "Invalid SQL args")
+
+	for _, ranges := range []string{
+		`[{"action": {"in": []}}]`,
+		`[{"action": {"nin": []}}]`,
+		`[{"action": {"in": "intent"}}]`,
+	} {
+		rawQuery, aerr = NewSearchRawQuery(`{"query": {"should": {"bool": [{"must": {"ranges": ` + ranges + `}}]}}}`)
+		assert.Nil(t, aerr, "Error while parsing search query")
+		aerr = rawQuery.validateRanges()
+		assert.NotNil(t, aerr, "Ranges should be invalid: "+ranges)
+		assert.Equal(t, "search.value.invalid", aerr.ErrorCode(), "Invalid error code")
+	}
+}
+
+func TestSetDataKeyPattern(t *testing.T) {
+	defer SetDataKeyPattern(DefaultDataKeyPattern)
+
+	assert.Nil(t, ValidateData(map[string]interface{}{"christmas-offer": "", "tax_2017": 1}), "Keys should be valid")
+	assert.Equal(t, "data.key.invalid", ValidateData(map[string]interface{}{"product.id": 1}).ErrorCode(), "Invalid error code")
+
+	assert.NotNil(t, SetDataKeyPattern("[a-z"), "Invalid pattern should not be set")
+	assert.Nil(t, SetDataKeyPattern("[a-z_]+"), "Error while setting pattern")
+	assert.Equal(t, "data.key.invalid", ValidateData(map[string]interface{}{"christmas-offer": ""}).ErrorCode(), "Invalid error code")
+	assert.Nil(t, ValidateData(map[string]interface{}{"status": ""}), "Key should be valid")
+}
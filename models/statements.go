@@ -0,0 +1,95 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+// Statement represents the lines of an account within a period along with its balances
+type Statement struct {
+	AccountID      string            `json:"account"`
+	From           string            `json:"from,omitempty"`
+	To             string            `json:"to,omitempty"`
+	OpeningBalance int               `json:"opening_balance"`
+	ClosingBalance int               `json:"closing_balance"`
+	Entries        []*StatementEntry `json:"entries"`
+}
+
+// StatementEntry represents a line of the account in a statement with the running balance
+type StatementEntry struct {
+	TransactionID string          `json:"transaction_id"`
+	Timestamp     string          `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+	Delta         int             `json:"delta"`
+	Balance       int             `json:"balance"`
+}
+
+// GetStatement returns the statement of an account from the lines of transactions made between
+// the `from` and `to` timestamps. The empty timestamps leave the period unbounded on that side.
+func (a *AccountDB) GetStatement(id string, from string, to string) (*Statement, ledgerError.ApplicationError) {
+	isExists, aerr := a.IsExists(id)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if !isExists {
+		return nil, AccountNotFoundError(id)
+	}
+
+	// Read the opening balance and the lines from the same snapshot
+	tx, err := a.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, DBError(err)
+	}
+	defer tx.Rollback()
+
+	statement := &Statement{AccountID: id, From: from, To: to, Entries: make([]*StatementEntry, 0)}
+	if from != "" {
+		q := `SELECT COALESCE(SUM(lines.delta), 0) FROM lines
+				JOIN transactions ON transactions.id = lines.transaction_id
+				WHERE lines.account_id = $1 AND transactions.timestamp < $2`
+		err = tx.QueryRow(q, id, from).Scan(&statement.OpeningBalance)
+		if err != nil {
+			return nil, DBError(err)
+		}
+	}
+
+	q := `SELECT transactions.id, transactions.timestamp, transactions.data, lines.delta FROM lines
+			JOIN transactions ON transactions.id = lines.transaction_id
+			WHERE lines.account_id = ?`
+	args := []interface{}{id}
+	if from != "" {
+		q += " AND transactions.timestamp >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		q += " AND transactions.timestamp <= ?"
+		args = append(args, to)
+	}
+	q += " ORDER BY transactions.timestamp, lines.id"
+
+	rows, err := tx.Query(enumerateSQLPlacholder(q), args...)
+	if err != nil {
+		return nil, DBError(err)
+	}
+	defer rows.Close()
+
+	balance := statement.OpeningBalance
+	for rows.Next() {
+		entry := &StatementEntry{}
+		if err := rows.Scan(&entry.TransactionID, &entry.Timestamp, &entry.Data, &entry.Delta); err != nil {
+			return nil, DBError(err)
+		}
+		balance += entry.Delta
+		entry.Balance = balance
+		statement.Entries = append(statement.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, DBError(err)
+	}
+	statement.ClosingBalance = balance
+
+	return statement, nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import "github.com/stretchr/testify/assert"
+
+func (ss *SearchSuite) TestGetStatement() {
+	t := ss.T()
+
+	statement, err := ss.accDB.GetStatement("acc1", "", "")
+	assert.Equal(t, nil, err, "Error while getting statement")
+	assert.Equal(t, 0, statement.OpeningBalance, "Opening balance doesn't match")
+	assert.Equal(t, 1500, statement.ClosingBalance, "Closing balance doesn't match")
+	assert.Equal(t, 3, len(statement.Entries), "Statement entries count doesn't match")
+	assert.Equal(t, "txn1", statement.Entries[0].TransactionID, "Transaction ID doesn't match")
+	assert.Equal(t, 1000, statement.Entries[0].Balance, "Running balance doesn't match")
+	assert.Equal(t, 1100, statement.Entries[1].Balance, "Running balance doesn't match")
+	assert.Equal(t, 1500, statement.Entries[2].Balance, "Running balance doesn't match")
+
+	statement, err = ss.accDB.GetStatement("acc2", "2100-01-01 00:00:00.000", "")
+	assert.Equal(t, nil, err, "Error while getting statement")
+	assert.Equal(t, -1500, statement.OpeningBalance, "Opening balance doesn't match")
+	assert.Equal(t, -1500, statement.ClosingBalance, "Closing balance doesn't match")
+	assert.Equal(t, 0, len(statement.Entries), "Statement should not have entries")
+
+	_, err = ss.accDB.GetStatement("acc0", "", "")
+	assert.Equal(t, "account.notfound", err.ErrorCode(), "Invalid error code")
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+	"github.com/lib/pq"
+)
+
+// Scopes of the API tokens
+const (
+	ScopeAccountsRead      = "accounts:read"
+	ScopeAccountsWrite     = "accounts:write"
+	ScopeTransactionsRead  = "transactions:read"
+	ScopeTransactionsWrite = "transactions:write"
+	// ScopeAdmin allows all the operations
+	ScopeAdmin = "admin"
+)
+
+var validScopes = map[string]bool{
+	ScopeAccountsRead:      true,
+	ScopeAccountsWrite:     true,
+	ScopeTransactionsRead:  true,
+	ScopeTransactionsWrite: true,
+	ScopeAdmin:             true,
+}
+
+// APIToken represents a named API token with its scopes and the accounts it is restricted to.
+// Only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	Name      string          `json:"name"`
+	TokenHash string          `json:"token_hash"`
+	Scopes    []string        `json:"scopes"`
+	Accounts  AccountPatterns `json:"accounts"`
+}
+
+// TokenStore finds the API tokens
+type TokenStore interface {
+	GetByToken(token string) (*APIToken, ledgerError.ApplicationError)
+	// HasTokens says whether any API token exists
+	HasTokens() (bool, ledgerError.ApplicationError)
+}
+
+// HashToken returns the hex encoded SHA-256 hash of the token
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// ValidateScopes validates the scopes of an API token
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return fmt.Errorf("Invalid scope: %v", scope)
+		}
+	}
+	return nil
+}
+
+// TokenDB provides the API tokens stored in the database
+type TokenDB struct {
+	db *sql.DB
+}
+
+// NewTokenDB returns a new instance of `TokenDB`
+func NewTokenDB(db *sql.DB) TokenDB {
+	return TokenDB{db: db}
+}
+
+// GetByToken returns the API token, or nil if the token doesn't exist
+func (t *TokenDB) GetByToken(token string) (*APIToken, ledgerError.ApplicationError) {
+	apiToken := &APIToken{TokenHash: HashToken(token)}
+	err := t.db.QueryRow("SELECT name, scopes, accounts FROM api_tokens WHERE token_hash=$1", apiToken.TokenHash).
+		Scan(&apiToken.Name, pq.Array(&apiToken.Scopes), pq.Array((*[]string)(&apiToken.Accounts)))
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, DBError(err)
+	}
+	return apiToken, nil
+}
+
+// HasTokens says whether any API token exists in the database
+func (t *TokenDB) HasTokens() (bool, ledgerError.ApplicationError) {
+	var exists bool
+	err := t.db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_tokens)").Scan(&exists)
+	if err != nil {
+		return false, DBError(err)
+	}
+	return exists, nil
+}
+
+// CreateToken generates a new API token with the name, scopes and account patterns, and returns the token
+func (t *TokenDB) CreateToken(name string, scopes []string, accounts AccountPatterns) (string, ledgerError.ApplicationError) {
+	if err := ValidateScopes(scopes); err != nil {
+		return "", TokenInvalidError(err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", InternalError(err)
+	}
+	token := hex.EncodeToString(secret)
+
+	// The nil arrays are stored as NULL, instead of empty arrays
+	if scopes == nil {
+		scopes = []string{}
+	}
+	if accounts == nil {
+		accounts = AccountPatterns{}
+	}
+	q := "INSERT INTO api_tokens (name, token_hash, scopes, accounts) VALUES ($1, $2, $3, $4)"
+	_, err := t.db.Exec(q, name, HashToken(token), pq.Array(scopes), pq.Array([]string(accounts)))
+	if err != nil {
+		return "", DBError(err)
+	}
+	return token, nil
+}
+
+// DeleteToken deletes the API token with the name
+func (t *TokenDB) DeleteToken(name string) ledgerError.ApplicationError {
+	_, err := t.db.Exec("DELETE FROM api_tokens WHERE name=$1", name)
+	if err != nil {
+		return DBError(err)
+	}
+	return nil
+}
+
+// TokenFile provides the API tokens loaded from a JSON file
+type TokenFile struct {
+	tokens map[string]*APIToken
+}
+
+// LoadTokenFile loads the API tokens from the JSON file having a list of `APIToken`
+func LoadTokenFile(path string) (*TokenFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []*APIToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+
+	file := &TokenFile{tokens: make(map[string]*APIToken, len(tokens))}
+	for _, token := range tokens {
+		if token.Name == "" || token.TokenHash == "" {
+			return nil, fmt.Errorf("Token should have a name and token_hash: %v", token.Name)
+		}
+		if err := ValidateScopes(token.Scopes); err != nil {
+			return nil, err
+		}
+		file.tokens[token.TokenHash] = token
+	}
+	return file, nil
+}
+
+// GetByToken returns the API token, or nil if the token doesn't exist
+func (f *TokenFile) GetByToken(token string) (*APIToken, ledgerError.ApplicationError) {
+	return f.tokens[HashToken(token)], nil
+}
+
+// HasTokens says whether the file has any API token
+func (f *TokenFile) HasTokens() (bool, ledgerError.ApplicationError) {
+	return len(f.tokens) != 0, nil
+}
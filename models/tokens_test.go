@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestLoadTokenFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "qledger-tokens-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`[
+	  {"name": "dashboard", "token_hash": "` + HashToken("XXX") + `", "scopes": ["accounts:read", "transactions:read"], "accounts": ["PARTNER42.*"]}
+	]`)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenFile, err := LoadTokenFile(file.Name())
+	assert.Equal(t, nil, err, "Error while loading tokens file")
+	token, aerr := tokenFile.GetByToken("XXX")
+	assert.Nil(t, aerr, "Error while getting token")
+	assert.Equal(t, "dashboard", token.Name, "Invalid token name")
+	assert.Equal(t, []string{"accounts:read", "transactions:read"}, token.Scopes, "Invalid token scopes")
+	assert.Equal(t, AccountPatterns{"PARTNER42.*"}, token.Accounts, "Invalid token accounts")
+	token, aerr = tokenFile.GetByToken("XYZ")
+	assert.Nil(t, aerr, "Error while getting token")
+	assert.Nil(t, token, "Token should not exist")
+}
+
+func TestValidateScopes(t *testing.T) {
+	assert.Equal(t, nil, ValidateScopes([]string{ScopeAccountsRead, ScopeAdmin}), "Scopes should be valid")
+	assert.NotNil(t, ValidateScopes([]string{"accounts:delete"}), "Scope should be invalid")
+}
+
+type TokensSuite struct {
+	suite.Suite
+	db *sql.DB
+}
+
+func (ts *TokensSuite) SetupSuite() {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	assert.NotEmpty(ts.T(), databaseURL)
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Panic("Unable to connect to Database:", err)
+	} else {
+		log.Println("Successfully established connection to database.")
+		ts.db = db
+	}
+}
+
+func (ts *TokensSuite) TestCreateToken() {
+	t := ts.T()
+
+	tokenDB := NewTokenDB(ts.db)
+	token, aerr := tokenDB.CreateToken("reports", []string{ScopeAccountsRead}, AccountPatterns{"PARTNER42.*"})
+	assert.Nil(t, aerr, "Error while creating token")
+	assert.NotEmpty(t, token, "Token should be generated")
+
+	apiToken, aerr := tokenDB.GetByToken(token)
+	assert.Nil(t, aerr, "Error while getting token")
+	assert.Equal(t, "reports", apiToken.Name, "Invalid token name")
+	assert.Equal(t, []string{ScopeAccountsRead}, apiToken.Scopes, "Invalid token scopes")
+	assert.Equal(t, AccountPatterns{"PARTNER42.*"}, apiToken.Accounts, "Invalid token accounts")
+
+	_, aerr = tokenDB.CreateToken("invalid", []string{"accounts:delete"}, nil)
+	assert.Equal(t, "token.invalid", aerr.ErrorCode(), "Invalid error code")
+
+	aerr = tokenDB.DeleteToken("reports")
+	assert.Nil(t, aerr, "Error while deleting token")
+	apiToken, aerr = tokenDB.GetByToken(token)
+	assert.Nil(t, aerr, "Error while getting token")
+	assert.Nil(t, apiToken, "Token should be deleted")
+}
+
+func TestTokensSuite(t *testing.T) {
+	suite.Run(t, new(TokensSuite))
+}
@@ -22,6 +22,8 @@ type Transaction struct {
 	Data      map[string]interface{} `json:"data"`
 	Timestamp string                 `json:"timestamp"`
 	Lines     []*TransactionLine     `json:"lines"`
+	// ExpectedVersion is the version the update of the transaction expects, unless it is 0
+	ExpectedVersion int64 `json:"expected_version,omitempty"`
 }
 
 // TransactionLine represents a transaction line in a ledger
@@ -41,7 +43,10 @@ func (t *Transaction) IsValid() bool {
 
 // TransactionDB is the interface to all transaction operations
 type TransactionDB struct {
-	db *sql.DB
+	db         *sql.DB
+	audit      *AuditInfo
+	frozenKeys *FrozenKeys
+	schemas    *DataSchemas
 }
 
 // NewTransactionDB returns a new instance of `TransactionDB`
@@ -49,6 +54,24 @@ func NewTransactionDB(db *sql.DB) TransactionDB {
 	return TransactionDB{db: db}
 }
 
+// WithAudit returns a copy of the `TransactionDB` which records its changes in the audit log as made by the caller
+func (t TransactionDB) WithAudit(audit *AuditInfo) TransactionDB {
+	t.audit = audit
+	return t
+}
+
+// WithFrozenKeys returns a copy of the `TransactionDB` which rejects the updates changing the frozen keys of the data
+func (t TransactionDB) WithFrozenKeys(frozenKeys *FrozenKeys) TransactionDB {
+	t.frozenKeys = frozenKeys
+	return t
+}
+
+// WithSchemas returns a copy of the `TransactionDB` which validates the patched data against the schemas
+func (t TransactionDB) WithSchemas(schemas *DataSchemas) TransactionDB {
+	t.schemas = schemas
+	return t
+}
+
 // IsExists says whether a transaction already exists or not
 func (t *TransactionDB) IsExists(id string) (bool, ledgerError.ApplicationError) {
 	var exists bool
@@ -60,26 +83,53 @@ func (t *TransactionDB) IsExists(id string) (bool, ledgerError.ApplicationError)
 	return exists, nil
 }
 
-// IsConflict says whether a transaction conflicts with an existing transaction
-func (t *TransactionDB) IsConflict(transaction *Transaction) (bool, ledgerError.ApplicationError) {
-	// Read existing lines
-	rows, err := t.db.Query("SELECT account_id, delta FROM lines WHERE transaction_id=$1", transaction.ID)
+// GetResultByID returns a transaction with the given ID in the format of `TransactionResult`
+func (t *TransactionDB) GetResultByID(id string) (*TransactionResult, ledgerError.ApplicationError) {
+	transaction, err := scanTransactionResult(t.db.QueryRow(transactionsSelectSQL+" WHERE id=$1", id))
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, TransactionNotFoundError(id)
+	case err != nil:
+		return nil, DBError(err)
+	}
+
+	return transaction, nil
+}
+
+// queryer is implemented by both `sql.DB` and `sql.Tx`
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getLines reads the lines of the transaction with the given ID
+func getLines(q queryer, id string) ([]*TransactionLine, error) {
+	rows, err := q.Query("SELECT account_id, delta FROM lines WHERE transaction_id=$1", id)
 	if err != nil {
 		log.Println("Error executing transaction lines query:", err)
-		return false, DBError(err)
+		return nil, err
 	}
 	defer rows.Close()
-	var existingLines []*TransactionLine
+	var lines []*TransactionLine
 	for rows.Next() {
 		line := &TransactionLine{}
 		if err := rows.Scan(&line.AccountID, &line.Delta); err != nil {
 			log.Println("Error scanning transaction lines:", err)
-			return false, DBError(err)
+			return nil, err
 		}
-		existingLines = append(existingLines, line)
+		lines = append(lines, line)
 	}
 	if err := rows.Err(); err != nil {
 		log.Println("Error iterating transaction lines rows:", err)
+		return nil, err
+	}
+	return lines, nil
+}
+
+// IsConflict says whether a transaction conflicts with an existing transaction
+func (t *TransactionDB) IsConflict(transaction *Transaction) (bool, ledgerError.ApplicationError) {
+	// Read existing lines
+	existingLines, err := getLines(t.db, transaction.ID)
+	if err != nil {
 		return false, DBError(err)
 	}
 
@@ -87,6 +137,74 @@ func (t *TransactionDB) IsConflict(transaction *Transaction) (bool, ledgerError.
 	return !containsSameElements(transaction.Lines, existingLines), nil
 }
 
+// isUniqueViolation says whether the error is caused by violating an unique constraint
+func isUniqueViolation(err error) bool {
+	pqErr, ok := errors.Cause(err).(*pq.Error)
+	return ok && pqErr.Code.Name() == "unique_violation"
+}
+
+// insertTransaction adds the transaction, its lines and the new accounts of the lines
+// using the given DB transaction, and records their creation in the audit log
+func insertTransaction(tx *sql.Tx, txn *Transaction, audit *AuditInfo) error {
+	// Accounts do not need to be predefined
+	// they are called into existence when they are first used.
+	for _, line := range txn.Lines {
+		var created bool
+		err := tx.QueryRow("INSERT INTO accounts (id) VALUES ($1) ON CONFLICT (id) DO NOTHING RETURNING true", line.AccountID).Scan(&created)
+		switch {
+		case err == sql.ErrNoRows:
+			continue
+		case err != nil:
+			return errors.Wrap(err, "insert account failed")
+		}
+		err = recordVersion(tx, AuditEntityAccount, line.AccountID)
+		if err != nil {
+			return errors.Wrap(err, "insert account version failed")
+		}
+		err = writeAudit(tx, audit, AuditEntityAccount, line.AccountID, AuditActionCreate, "", "{}")
+		if err != nil {
+			return errors.Wrap(err, "insert audit log failed")
+		}
+	}
+
+	// Add transaction
+	data, err := json.Marshal(txn.Data)
+	if err != nil {
+		return errors.Wrap(err, "transaction data parse error")
+	}
+	transactionData := "{}"
+	if txn.Data != nil && data != nil {
+		transactionData = string(data)
+	}
+
+	if txn.Timestamp == "" {
+		txn.Timestamp = time.Now().UTC().Format(LedgerTimestampLayout)
+	}
+
+	_, err = tx.Exec("INSERT INTO transactions (id, timestamp, data) VALUES ($1, $2, $3)", txn.ID, txn.Timestamp, transactionData)
+	if err != nil {
+		return errors.Wrap(err, "insert transaction failed")
+	}
+
+	// Add transaction lines
+	for _, line := range txn.Lines {
+		_, err = tx.Exec("INSERT INTO lines (transaction_id, account_id, delta) VALUES ($1, $2, $3)", txn.ID, line.AccountID, line.Delta)
+		if err != nil {
+			return errors.Wrap(err, "insert lines failed")
+		}
+	}
+
+	err = recordVersion(tx, AuditEntityTransaction, txn.ID)
+	if err != nil {
+		return errors.Wrap(err, "insert transaction version failed")
+	}
+	err = writeAudit(tx, audit, AuditEntityTransaction, txn.ID, AuditActionCreate, "", transactionData)
+	if err != nil {
+		return errors.Wrap(err, "insert audit log failed")
+	}
+	return nil
+}
+
 // Transact creates the input transaction in the DB
 func (t *TransactionDB) Transact(txn *Transaction) bool {
 	// Start the transaction
@@ -108,61 +226,223 @@ func (t *TransactionDB) Transact(txn *Transaction) bool {
 		return false
 	}
 
-	// Accounts do not need to be predefined
-	// they are called into existence when they are first used.
-	for _, line := range txn.Lines {
-		_, err = tx.Exec("INSERT INTO accounts (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", line.AccountID)
-		if err != nil {
-			return handleTransactionError(tx, errors.Wrap(err, "insert account failed"))
+	err = insertTransaction(tx, txn, t.audit)
+	if err != nil {
+		// Ignore duplicate transactions and return success response
+		if isUniqueViolation(err) {
+			log.Println("Ignoring duplicate transaction of id:", txn.ID)
+			err = tx.Rollback()
+			if err != nil {
+				log.Println("Error rolling back transaction:", err)
+			}
+			return true
 		}
+		return handleTransactionError(tx, err)
 	}
 
-	// Add transaction
-	data, err := json.Marshal(txn.Data)
+	// Commit the entire transaction
+	err = tx.Commit()
 	if err != nil {
-		return handleTransactionError(tx, errors.Wrap(err, "transaction data parse error"))
+		return handleTransactionError(tx, errors.Wrap(err, "commit transaction failed"))
 	}
-	transactionData := "{}"
-	if txn.Data != nil && data != nil {
-		transactionData = string(data)
+
+	return true
+}
+
+const (
+	// BatchStatusCreated says that the transaction in the batch is created
+	BatchStatusCreated = "created"
+	// BatchStatusDuplicate says that the transaction in the batch is an exact duplicate of an existing transaction
+	BatchStatusDuplicate = "duplicate"
+	// BatchStatusConflict says that the transaction in the batch conflicts with an existing transaction
+	BatchStatusConflict = "conflict"
+	// BatchStatusInvalid says that the transaction in the batch is invalid
+	BatchStatusInvalid = "invalid"
+	// BatchStatusAborted says that the transaction in the batch is not created, as the batch is rolled back
+	BatchStatusAborted = "aborted"
+)
+
+// BatchItemResult represents the result of a transaction in a batch
+type BatchItemResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Error is the reason of the invalid transaction, if known
+	Error *ledgerError.Response `json:"error,omitempty"`
+}
+
+// TransactBatch creates all the input transactions in a single DB transaction.
+// The exact duplicates of the existing transactions are ignored. If any of the transactions
+// conflicts with an existing transaction, none of the transactions in the batch are created.
+func (t *TransactionDB) TransactBatch(txns []*Transaction) ([]*BatchItemResult, ledgerError.ApplicationError) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, DBError(err)
 	}
+	defer tx.Rollback()
 
-	if txn.Timestamp == "" {
-		txn.Timestamp = time.Now().UTC().Format(LedgerTimestampLayout)
+	// checkExisting says whether the transaction is a duplicate or a conflict of an existing transaction
+	checkExisting := func(txn *Transaction) (string, error) {
+		existingLines, err := getLines(tx, txn.ID)
+		if err != nil {
+			return "", err
+		}
+		if containsSameElements(txn.Lines, existingLines) {
+			return BatchStatusDuplicate, nil
+		}
+		return BatchStatusConflict, nil
 	}
 
-	_, err = tx.Exec("INSERT INTO transactions (id, timestamp, data) VALUES ($1, $2, $3)", txn.ID, txn.Timestamp, transactionData)
-	if err != nil {
-		// Ignore duplicate transactions and return success response
-		if err.(*pq.Error).Code.Name() == "unique_violation" {
-			log.Println("Ignoring duplicate transaction of id:", txn.ID)
-			err = tx.Rollback()
+	results := make([]*BatchItemResult, 0, len(txns))
+	isConflict := false
+	for _, txn := range txns {
+		result := &BatchItemResult{ID: txn.ID}
+		results = append(results, result)
+
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT id FROM transactions WHERE id=$1)", txn.ID).Scan(&exists)
+		if err != nil {
+			return nil, DBError(err)
+		}
+		if exists {
+			result.Status, err = checkExisting(txn)
 			if err != nil {
-				log.Println("Error rolling back transaction:", err)
+				return nil, DBError(err)
 			}
-			return true
+			isConflict = isConflict || result.Status == BatchStatusConflict
+			continue
 		}
-		return handleTransactionError(tx, errors.Wrap(err, "insert transaction failed"))
-	}
 
-	// Add transaction lines
-	for _, line := range txn.Lines {
-		_, err = tx.Exec("INSERT INTO lines (transaction_id, account_id, delta) VALUES ($1, $2, $3)", txn.ID, line.AccountID, line.Delta)
+		// The savepoint helps to recover from the transactions created concurrently outside the batch
+		_, err = tx.Exec("SAVEPOINT batch_item")
+		if err != nil {
+			return nil, DBError(err)
+		}
+		err = insertTransaction(tx, txn, t.audit)
 		if err != nil {
-			return handleTransactionError(tx, errors.Wrap(err, "insert lines failed"))
+			if !isUniqueViolation(err) {
+				return nil, DBError(err)
+			}
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT batch_item")
+			if err != nil {
+				return nil, DBError(err)
+			}
+			result.Status, err = checkExisting(txn)
+			if err != nil {
+				return nil, DBError(err)
+			}
+			isConflict = isConflict || result.Status == BatchStatusConflict
+			continue
 		}
+		result.Status = BatchStatusCreated
+	}
+
+	if isConflict {
+		for _, result := range results {
+			if result.Status == BatchStatusCreated {
+				result.Status = BatchStatusAborted
+			}
+		}
+		return results, nil
 	}
 
-	// Commit the entire transaction
 	err = tx.Commit()
 	if err != nil {
-		return handleTransactionError(tx, errors.Wrap(err, "commit transaction failed"))
+		return nil, DBError(err)
 	}
+	return results, nil
+}
 
-	return true
+// Reverse creates the reversal transaction with the negated lines of the transaction with the given ID.
+// The reversal is recorded in the `reversals` table, which allows a single reversal of a transaction.
+// Both the transactions are also linked to each other by the `reverses` and `reversed_by` keys in their data,
+// which are reserved for the reversals. The data of both the transactions is validated against the schemas once they are linked.
+// Repeating the same reversal is ignored and any other reversal of the transaction results in error.
+func (t *TransactionDB) Reverse(id string, reversal *Transaction) ledgerError.ApplicationError {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return DBError(err)
+	}
+	defer tx.Rollback()
+
+	// Lock the transaction to prevent concurrent reversals of it
+	var rawData []byte
+	err = tx.QueryRow("SELECT data FROM transactions WHERE id=$1 FOR UPDATE", id).Scan(&rawData)
+	switch {
+	case err == sql.ErrNoRows:
+		return TransactionNotFoundError(id)
+	case err != nil:
+		return DBError(err)
+	}
+	var reversedBy string
+	err = tx.QueryRow("SELECT reversal_id FROM reversals WHERE transaction_id=$1", id).Scan(&reversedBy)
+	switch {
+	case err == nil && reversedBy == reversal.ID:
+		log.Println("Ignoring duplicate reversal of id:", reversal.ID)
+		return nil
+	case err == nil:
+		return TransactionReversedError(id)
+	case err != sql.ErrNoRows:
+		return DBError(err)
+	}
+
+	lines, err := getLines(tx, id)
+	if err != nil {
+		return DBError(err)
+	}
+	reversal.Lines = make([]*TransactionLine, 0, len(lines))
+	for _, line := range lines {
+		reversal.Lines = append(reversal.Lines, &TransactionLine{AccountID: line.AccountID, Delta: -line.Delta})
+	}
+	if reversal.Data == nil {
+		reversal.Data = make(map[string]interface{})
+	}
+	reversal.Data["reverses"] = id
+	if aerr := t.schemas.Validate(AuditEntityTransaction, reversal.Data); aerr != nil {
+		return aerr
+	}
+
+	err = insertTransaction(tx, reversal, t.audit)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return TransactionConflictError(reversal.ID)
+		}
+		return DBError(err)
+	}
+	_, err = tx.Exec("INSERT INTO reversals (transaction_id, reversal_id) VALUES ($1, $2)", id, reversal.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return TransactionReversedError(id)
+		}
+		return DBError(err)
+	}
+	var after string
+	q := "UPDATE transactions SET data = jsonb_set(data, '{reversed_by}', to_jsonb($1::text)), version = version + 1 WHERE id = $2 RETURNING data"
+	err = tx.QueryRow(q, reversal.ID, id).Scan(&after)
+	if err != nil {
+		return DBError(err)
+	}
+	if aerr := validateDataSchema(t.schemas, AuditEntityTransaction, after); aerr != nil {
+		return aerr
+	}
+	err = recordVersion(tx, AuditEntityTransaction, id)
+	if err != nil {
+		return DBError(err)
+	}
+	err = writeAudit(tx, t.audit, AuditEntityTransaction, id, AuditActionUpdate, string(rawData), after)
+	if err != nil {
+		return DBError(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return DBError(err)
+	}
+	return nil
 }
 
-// UpdateTransaction updates data of the given transaction
+// UpdateTransaction updates data of the given transaction.
+// The transaction is updated only if it has the expected version, when the expected version is set,
+// and the update doesn't change the frozen keys of the data.
 func (t *TransactionDB) UpdateTransaction(txn *Transaction) ledgerError.ApplicationError {
 	data, err := json.Marshal(txn.Data)
 	if err != nil {
@@ -173,8 +453,47 @@ func (t *TransactionDB) UpdateTransaction(txn *Transaction) ledgerError.Applicat
 		tData = string(data)
 	}
 
-	q := "UPDATE transactions SET data = $1 WHERE id = $2"
-	_, err = t.db.Exec(q, tData, txn.ID)
+	tx, err := t.db.Begin()
+	if err != nil {
+		return DBError(err)
+	}
+	defer tx.Rollback()
+
+	// Lock the transaction to record the data it had before the update
+	var before string
+	var current int64
+	err = tx.QueryRow("SELECT data, version FROM transactions WHERE id = $1 FOR UPDATE", txn.ID).Scan(&before, &current)
+	switch {
+	case err == sql.ErrNoRows:
+		return TransactionNotFoundError(txn.ID)
+	case err != nil:
+		return DBError(err)
+	}
+	var after string
+	q := "UPDATE transactions SET data = $1, version = version + 1 WHERE id = $2 AND ($3::bigint = 0 OR version = $3::bigint) RETURNING data"
+	err = tx.QueryRow(q, tData, txn.ID, txn.ExpectedVersion).Scan(&after)
+	switch {
+	case err == sql.ErrNoRows:
+		return VersionMismatchError(txn.ID, txn.ExpectedVersion, current)
+	case err != nil:
+		return DBError(err)
+	}
+	if aerr := checkFrozenKeys(t.frozenKeys, txn.ID, before, after); aerr != nil {
+		return aerr
+	}
+	if aerr := checkReversalKeys(txn.ID, before, after); aerr != nil {
+		return aerr
+	}
+	err = recordVersion(tx, AuditEntityTransaction, txn.ID)
+	if err != nil {
+		return DBError(err)
+	}
+	err = writeAudit(tx, t.audit, AuditEntityTransaction, txn.ID, AuditActionUpdate, before, after)
+	if err != nil {
+		return DBError(err)
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return DBError(err)
 	}
@@ -241,6 +241,179 @@ func (ts *TransactionsModelSuite) TestTransactWithBoundaryValues() {
 	// The test case is written in `package controllers` using JSON
 }
 
+func (ts *TransactionsModelSuite) TestReverse() {
+	t := ts.T()
+
+	transactionDB := NewTransactionDB(ts.db)
+	transaction := &Transaction{
+		ID: "t006",
+		Lines: []*TransactionLine{
+			&TransactionLine{
+				AccountID: "a1",
+				Delta:     100,
+			},
+			&TransactionLine{
+				AccountID: "a2",
+				Delta:     -100,
+			},
+		},
+	}
+	done := transactionDB.Transact(transaction)
+	assert.Equal(t, true, done, "Transaction should be created")
+
+	err := transactionDB.Reverse("t006", &Transaction{ID: "t006-reversal"})
+	assert.Equal(t, nil, err, "Error while reversing transaction")
+	reversal := &Transaction{
+		ID: "t006-reversal",
+		Lines: []*TransactionLine{
+			&TransactionLine{
+				AccountID: "a1",
+				Delta:     -100,
+			},
+			&TransactionLine{
+				AccountID: "a2",
+				Delta:     100,
+			},
+		},
+	}
+	conflicts, err := transactionDB.IsConflict(reversal)
+	assert.Equal(t, nil, err, "Error while checking for conflicting transaction")
+	assert.Equal(t, false, conflicts, "Reversal should have the negated lines")
+
+	// Repeated reversal
+	err = transactionDB.Reverse("t006", &Transaction{ID: "t006-reversal"})
+	assert.Equal(t, nil, err, "Repeated reversal should be ignored")
+
+	// Another reversal
+	err = transactionDB.Reverse("t006", &Transaction{ID: "t006-reversal-2"})
+	assert.Equal(t, "transaction.reversed", err.ErrorCode(), "Transaction should not be reversed twice")
+	exists, err := transactionDB.IsExists("t006-reversal-2")
+	assert.Equal(t, nil, err, "Error while checking for existing transaction")
+	assert.Equal(t, false, exists, "Transaction should not exist")
+
+	// The links of the reversal in the data can't be changed
+	err = transactionDB.UpdateTransaction(&Transaction{ID: "t006", Data: map[string]interface{}{}})
+	assert.Equal(t, "data.key.frozen", err.ErrorCode(), "Reversal link should not be removed")
+	err = transactionDB.UpdateTransaction(&Transaction{ID: "t006-reversal", Data: map[string]interface{}{"reverses": "t005"}})
+	assert.Equal(t, "data.key.frozen", err.ErrorCode(), "Reversal link should not be changed")
+
+	// The reversal is recorded regardless of the data
+	_, dberr := ts.db.Exec("UPDATE transactions SET data = '{}' WHERE id = 't006'")
+	assert.Equal(t, nil, dberr, "Error while updating transaction data")
+	err = transactionDB.Reverse("t006", &Transaction{ID: "t006-reversal-2"})
+	assert.Equal(t, "transaction.reversed", err.ErrorCode(), "Transaction should not be reversed twice")
+
+	// Non-existing transaction
+	err = transactionDB.Reverse("t000", &Transaction{ID: "t000-reversal"})
+	assert.Equal(t, "transaction.notfound", err.ErrorCode(), "Non-existing transaction should not be reversed")
+
+	// The reversal is validated against the schemas along with its link
+	done = transactionDB.Transact(&Transaction{
+		ID: "t011",
+		Lines: []*TransactionLine{
+			&TransactionLine{AccountID: "a1", Delta: 100},
+			&TransactionLine{AccountID: "a2", Delta: -100},
+		},
+	})
+	assert.Equal(t, true, done, "Transaction should be created")
+	schemas := &DataSchemas{Transactions: &SchemaSet{
+		Discriminator: "action",
+		Default:       &JSONSchema{Properties: map[string]*JSONSchema{"reverses": &JSONSchema{never: true}}},
+	}}
+	schemaTransactionDB := transactionDB.WithSchemas(schemas)
+	err = schemaTransactionDB.Reverse("t011", &Transaction{ID: "t011-reversal"})
+	assert.Equal(t, "data.schema.invalid", err.ErrorCode(), "Reversal violating the schema should not be created")
+	exists, err = transactionDB.IsExists("t011-reversal")
+	assert.Equal(t, nil, err, "Error while checking for existing transaction")
+	assert.Equal(t, false, exists, "Transaction should not exist")
+}
+
+func (ts *TransactionsModelSuite) TestUpdateFrozenKeys() {
+	t := ts.T()
+
+	transactionDB := NewTransactionDB(ts.db).WithFrozenKeys(&FrozenKeys{
+		Keys:    []string{"order_id"},
+		Actions: map[string][]string{"refund": []string{"refund_id"}},
+	})
+	transaction := &Transaction{
+		ID:   "t007",
+		Data: map[string]interface{}{"action": "refund", "order_id": "o1", "refund_id": "r1"},
+		Lines: []*TransactionLine{
+			&TransactionLine{AccountID: "a1", Delta: 100},
+			&TransactionLine{AccountID: "a2", Delta: -100},
+		},
+	}
+	done := transactionDB.Transact(transaction)
+	assert.Equal(t, true, done, "Transaction should be created")
+
+	err := transactionDB.UpdateTransaction(&Transaction{ID: "t007", Data: map[string]interface{}{
+		"action": "refund", "order_id": "o2", "refund_id": "r1",
+	}})
+	assert.Equal(t, "data.key.frozen", err.ErrorCode(), "Frozen key should not be changed")
+	patch, err := NewDataPatch("t007", PatchContentTypeMerge, []byte(`{"refund_id": null}`))
+	assert.Equal(t, nil, err, "Error while parsing patch")
+	err = transactionDB.PatchTransaction(patch)
+	assert.Equal(t, "data.key.frozen", err.ErrorCode(), "Frozen key should not be removed")
+
+	err = transactionDB.UpdateTransaction(&Transaction{ID: "t007", Data: map[string]interface{}{
+		"action": "refund", "order_id": "o1", "refund_id": "r1", "status": "completed",
+	}})
+	assert.Equal(t, nil, err, "Error while updating transaction")
+	transactionResult, err := transactionDB.GetResultByID("t007")
+	assert.Equal(t, nil, err, "Error while getting transaction")
+	assert.Equal(t, int64(2), transactionResult.Version, "Rejected updates should not change the version")
+}
+
+func (ts *TransactionsModelSuite) TestTransactBatch() {
+	t := ts.T()
+
+	transactionDB := NewTransactionDB(ts.db)
+	newTransaction := func(id string, delta int) *Transaction {
+		return &Transaction{
+			ID: id,
+			Lines: []*TransactionLine{
+				&TransactionLine{
+					AccountID: "a1",
+					Delta:     delta,
+				},
+				&TransactionLine{
+					AccountID: "a2",
+					Delta:     -delta,
+				},
+			},
+		}
+	}
+
+	results, err := transactionDB.TransactBatch([]*Transaction{
+		newTransaction("t007", 100),
+		newTransaction("t008", 200),
+	})
+	assert.Equal(t, nil, err, "Error while creating batch")
+	assert.Equal(t, BatchStatusCreated, results[0].Status, "Transaction should be created")
+	assert.Equal(t, BatchStatusCreated, results[1].Status, "Transaction should be created")
+
+	// Batch with duplicate transaction
+	results, err = transactionDB.TransactBatch([]*Transaction{
+		newTransaction("t008", 200),
+		newTransaction("t009", 300),
+	})
+	assert.Equal(t, nil, err, "Error while creating batch")
+	assert.Equal(t, BatchStatusDuplicate, results[0].Status, "Transaction should be duplicate")
+	assert.Equal(t, BatchStatusCreated, results[1].Status, "Transaction should be created")
+
+	// Batch with conflicting transaction
+	results, err = transactionDB.TransactBatch([]*Transaction{
+		newTransaction("t010", 100),
+		newTransaction("t009", 400),
+	})
+	assert.Equal(t, nil, err, "Error while creating batch")
+	assert.Equal(t, BatchStatusAborted, results[0].Status, "Transaction should be aborted")
+	assert.Equal(t, BatchStatusConflict, results[1].Status, "Transaction should be conflict")
+	exists, err := transactionDB.IsExists("t010")
+	assert.Equal(t, nil, err, "Error while checking for existing transaction")
+	assert.Equal(t, false, exists, "Transaction should not exist")
+}
+
 func (ts *TransactionsModelSuite) TearDownSuite() {
 	log.Println("Cleaning up the test database")
 
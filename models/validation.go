@@ -0,0 +1,56 @@
+package models
+
+import (
+	"regexp"
+	"time"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+// DefaultDataKeyPattern is the default grammar of the keys of the JSON data of accounts and transactions
+const DefaultDataKeyPattern = `[a-zA-Z_][a-zA-Z0-9_-]*`
+
+var validDataKey = regexp.MustCompile(`^(?:` + DefaultDataKeyPattern + `)$`)
+
+// SetDataKeyPattern sets the grammar of the keys of the JSON data, which the whole key should match.
+// It should be set only while starting the server, before validating any data.
+func SetDataKeyPattern(pattern string) error {
+	validKey, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return err
+	}
+	validDataKey = validKey
+	return nil
+}
+
+// ValidateData validates the keys of the JSON data of accounts and transactions
+func ValidateData(data map[string]interface{}) ledgerError.ApplicationError {
+	for key := range data {
+		if !validDataKey.MatchString(key) {
+			return DataKeyInvalidError(key)
+		}
+	}
+	return nil
+}
+
+// ValidateReservedKeys validates that the JSON data of the new transactions
+// doesn't have the keys reserved for linking the reversals
+func ValidateReservedKeys(data map[string]interface{}) ledgerError.ApplicationError {
+	for _, key := range reversalKeys {
+		if _, ok := data[key]; ok {
+			return DataKeyReservedError(key)
+		}
+	}
+	return nil
+}
+
+// ValidateTimestamp validates the format of the transaction timestamp if present
+func ValidateTimestamp(timestamp string) ledgerError.ApplicationError {
+	if timestamp == "" {
+		return nil
+	}
+	if _, err := time.Parse(LedgerTimestampLayout, timestamp); err != nil {
+		return TimestampInvalidError(timestamp)
+	}
+	return nil
+}
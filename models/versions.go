@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	ledgerError "github.com/RealImage/QLedger/errors"
+)
+
+// DataVersion represents a version of the data of an account or transaction
+type DataVersion struct {
+	Version   int64           `json:"version"`
+	Timestamp string          `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// versionsSQL has the queries of the version history of the entity types
+var versionsSQL = map[string]struct {
+	insert    string
+	insertAll string
+	selectAll string
+	selectOne string
+}{
+	AuditEntityAccount: {
+		insert:    "INSERT INTO account_versions (account_id, version, data) SELECT id, version, data FROM accounts WHERE id = $1",
+		insertAll: "INSERT INTO account_versions (account_id, version, data) SELECT id, version, data FROM accounts WHERE id = ANY($1)",
+		selectAll: "SELECT version, timestamp, data FROM account_versions WHERE account_id = $1 ORDER BY version",
+		selectOne: "SELECT version, timestamp, data FROM account_versions WHERE account_id = $1 AND version = $2",
+	},
+	AuditEntityTransaction: {
+		insert:    "INSERT INTO transaction_versions (transaction_id, version, data) SELECT id, version, data FROM transactions WHERE id = $1",
+		insertAll: "INSERT INTO transaction_versions (transaction_id, version, data) SELECT id, version, data FROM transactions WHERE id = ANY($1)",
+		selectAll: "SELECT version, timestamp, data FROM transaction_versions WHERE transaction_id = $1 ORDER BY version",
+		selectOne: "SELECT version, timestamp, data FROM transaction_versions WHERE transaction_id = $1 AND version = $2",
+	},
+}
+
+// recordVersion copies the current version of the data of an account or transaction to its version history.
+// It is called with the DB transaction creating or updating the data, so that both are committed together.
+func recordVersion(tx execer, entityType string, id string) error {
+	_, err := tx.Exec(versionsSQL[entityType].insert, id)
+	return err
+}
+
+// getVersions returns all the versions of the data of an account or transaction in the ascending order
+func getVersions(db *sql.DB, entityType string, id string) ([]*DataVersion, error) {
+	rows, err := db.Query(versionsSQL[entityType].selectAll, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make([]*DataVersion, 0)
+	for rows.Next() {
+		version := &DataVersion{}
+		if err := rows.Scan(&version.Version, &version.Timestamp, &version.Data); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// getVersion returns a version of the data of an account or transaction
+func getVersion(db *sql.DB, entityType string, id string, v int64) (*DataVersion, error) {
+	version := &DataVersion{}
+	err := db.QueryRow(versionsSQL[entityType].selectOne, id, v).Scan(&version.Version, &version.Timestamp, &version.Data)
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// GetVersions returns all the versions of the data of the account
+func (a *AccountDB) GetVersions(id string) ([]*DataVersion, ledgerError.ApplicationError) {
+	versions, err := getVersions(a.db, AuditEntityAccount, id)
+	if err != nil {
+		return nil, DBError(err)
+	}
+	if len(versions) == 0 {
+		return nil, AccountNotFoundError(id)
+	}
+	return versions, nil
+}
+
+// GetVersion returns a version of the data of the account
+func (a *AccountDB) GetVersion(id string, v int64) (*DataVersion, ledgerError.ApplicationError) {
+	version, err := getVersion(a.db, AuditEntityAccount, id, v)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, VersionNotFoundError(id, v)
+	case err != nil:
+		return nil, DBError(err)
+	}
+	return version, nil
+}
+
+// GetVersions returns all the versions of the data of the transaction
+func (t *TransactionDB) GetVersions(id string) ([]*DataVersion, ledgerError.ApplicationError) {
+	versions, err := getVersions(t.db, AuditEntityTransaction, id)
+	if err != nil {
+		return nil, DBError(err)
+	}
+	if len(versions) == 0 {
+		return nil, TransactionNotFoundError(id)
+	}
+	return versions, nil
+}
+
+// GetVersion returns a version of the data of the transaction
+func (t *TransactionDB) GetVersion(id string, v int64) (*DataVersion, ledgerError.ApplicationError) {
+	version, err := getVersion(t.db, AuditEntityTransaction, id, v)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, VersionNotFoundError(id, v)
+	case err != nil:
+		return nil, DBError(err)
+	}
+	return version, nil
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type VersionsSuite struct {
+	suite.Suite
+	db *sql.DB
+}
+
+func (vs *VersionsSuite) SetupTest() {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	assert.NotEmpty(vs.T(), databaseURL)
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Panic("Unable to connect to Database:", err)
+	} else {
+		log.Println("Successfully established connection to database.")
+		vs.db = db
+	}
+}
+
+func (vs *VersionsSuite) TestAccountVersions() {
+	t := vs.T()
+
+	accountsDB := NewAccountDB(vs.db)
+	aerr := accountsDB.CreateAccount(&Account{ID: "versions-acc-1", Data: map[string]interface{}{"status": "new"}})
+	assert.Nil(t, aerr, "Error while creating account")
+	for _, status := range []string{"active", "closed"} {
+		aerr = accountsDB.UpdateAccount(&Account{ID: "versions-acc-1", Data: map[string]interface{}{"status": status}})
+		assert.Nil(t, aerr, "Error while updating account")
+	}
+
+	versions, aerr := accountsDB.GetVersions("versions-acc-1")
+	assert.Nil(t, aerr, "Error while getting account versions")
+	assert.Equal(t, 3, len(versions), "Invalid number of versions")
+	for i, status := range []string{"new", "active", "closed"} {
+		assert.Equal(t, int64(i+1), versions[i].Version, "Invalid version")
+		assert.JSONEq(t, `{"status": "`+status+`"}`, string(versions[i].Data), "Invalid version data")
+	}
+
+	version, aerr := accountsDB.GetVersion("versions-acc-1", 2)
+	assert.Nil(t, aerr, "Error while getting account version")
+	assert.JSONEq(t, `{"status": "active"}`, string(version.Data), "Invalid version data")
+
+	// The update expecting a stale version is rejected
+	aerr = accountsDB.UpdateAccount(&Account{ID: "versions-acc-1", ExpectedVersion: 2})
+	assert.Equal(t, "version.mismatch", aerr.ErrorCode(), "Invalid error code")
+	aerr = accountsDB.UpdateAccount(&Account{ID: "versions-acc-1", Data: map[string]interface{}{"status": "active"}, ExpectedVersion: 3})
+	assert.Nil(t, aerr, "Error while updating account")
+	account, aerr := accountsDB.GetResultByID("versions-acc-1", "")
+	assert.Nil(t, aerr, "Error while getting account")
+	assert.Equal(t, int64(4), account.Version, "Invalid account version")
+
+	_, aerr = accountsDB.GetVersion("versions-acc-1", 5)
+	assert.Equal(t, "version.notfound", aerr.ErrorCode(), "Invalid error code")
+	_, aerr = accountsDB.GetVersions("versions-acc-missing")
+	assert.Equal(t, "account.notfound", aerr.ErrorCode(), "Invalid error code")
+}
+
+func (vs *VersionsSuite) TestTransactionVersions() {
+	t := vs.T()
+
+	transactionDB := NewTransactionDB(vs.db)
+	done := transactionDB.Transact(&Transaction{
+		ID:   "versions-txn-1",
+		Data: map[string]interface{}{"status": "pending"},
+		Lines: []*TransactionLine{
+			&TransactionLine{AccountID: "versions-acc-2", Delta: 100},
+			&TransactionLine{AccountID: "versions-acc-3", Delta: -100},
+		},
+	})
+	assert.Equal(t, true, done, "Transaction should be created")
+	aerr := transactionDB.Reverse("versions-txn-1", &Transaction{ID: "versions-txn-2"})
+	assert.Nil(t, aerr, "Error while reversing transaction")
+
+	versions, aerr := transactionDB.GetVersions("versions-txn-1")
+	assert.Nil(t, aerr, "Error while getting transaction versions")
+	assert.Equal(t, 2, len(versions), "Invalid number of versions")
+	assert.JSONEq(t, `{"status": "pending"}`, string(versions[0].Data), "Invalid version data")
+	assert.JSONEq(t, `{"status": "pending", "reversed_by": "versions-txn-2"}`, string(versions[1].Data), "Invalid version data")
+
+	// The accounts created by the transaction lines have their first version
+	accountsDB := NewAccountDB(vs.db)
+	accountVersions, aerr := accountsDB.GetVersions("versions-acc-2")
+	assert.Nil(t, aerr, "Error while getting account versions")
+	assert.Equal(t, 1, len(accountVersions), "Invalid number of versions")
+}
+
+func (vs *VersionsSuite) TearDownSuite() {
+	t := vs.T()
+	_, err := vs.db.Exec("DELETE FROM lines WHERE transaction_id LIKE $1", "versions-txn-%")
+	if err != nil {
+		t.Fatal("Error deleting lines:", err)
+	}
+	_, err = vs.db.Exec("DELETE FROM transactions WHERE id LIKE $1", "versions-txn-%")
+	if err != nil {
+		t.Fatal("Error deleting transactions:", err)
+	}
+	_, err = vs.db.Exec("DELETE FROM accounts WHERE id LIKE $1", "versions-acc-%")
+	if err != nil {
+		t.Fatal("Error deleting accounts:", err)
+	}
+}
+
+func TestVersionsSuite(t *testing.T) {
+	suite.Run(t, new(VersionsSuite))
+}
@@ -9,16 +9,56 @@ SET row_security = off;
 CREATE EXTENSION IF NOT EXISTS plpgsql WITH SCHEMA pg_catalog;
 COMMENT ON EXTENSION plpgsql IS 'PL/pgSQL procedural language';
 SET search_path = public, pg_catalog;
+CREATE FUNCTION audit_log_append_only() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$;
 SET default_tablespace = '';
 SET default_with_oids = false;
+CREATE TABLE account_versions (
+    account_id character varying NOT NULL,
+    version bigint NOT NULL,
+    "timestamp" timestamp without time zone DEFAULT timezone('utc'::text, now()) NOT NULL,
+    data jsonb DEFAULT '{}'::jsonb NOT NULL
+);
 CREATE TABLE accounts (
     id character varying NOT NULL,
-    data jsonb DEFAULT '{}'::jsonb NOT NULL
+    data jsonb DEFAULT '{}'::jsonb NOT NULL,
+    version bigint DEFAULT 1 NOT NULL
 );
+CREATE TABLE api_tokens (
+    name character varying NOT NULL,
+    token_hash character varying NOT NULL,
+    scopes character varying[] DEFAULT '{}'::character varying[] NOT NULL,
+    accounts character varying[] DEFAULT '{}'::character varying[] NOT NULL
+);
+CREATE TABLE audit_log (
+    id bigint NOT NULL,
+    "timestamp" timestamp without time zone DEFAULT timezone('utc'::text, now()) NOT NULL,
+    identity character varying DEFAULT ''::character varying NOT NULL,
+    request_id character varying DEFAULT ''::character varying NOT NULL,
+    endpoint character varying DEFAULT ''::character varying NOT NULL,
+    entity_type character varying NOT NULL,
+    entity_id character varying NOT NULL,
+    action character varying NOT NULL,
+    before jsonb,
+    after jsonb
+);
+CREATE SEQUENCE audit_log_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+ALTER SEQUENCE audit_log_id_seq OWNED BY audit_log.id;
 CREATE TABLE current_balances (
     id character varying,
     data jsonb,
-    balance numeric
+    balance numeric,
+    version bigint
 );
 ALTER TABLE ONLY current_balances REPLICA IDENTITY NOTHING;
 CREATE TABLE lines (
@@ -40,37 +80,74 @@ CREATE SEQUENCE lines_id_seq
     NO MAXVALUE
     CACHE 1;
 ALTER SEQUENCE lines_id_seq OWNED BY lines.id;
+CREATE TABLE reversals (
+    transaction_id character varying NOT NULL,
+    reversal_id character varying NOT NULL
+);
 CREATE TABLE schema_migrations (
     version bigint NOT NULL,
     dirty boolean NOT NULL
 );
+CREATE TABLE transaction_versions (
+    transaction_id character varying NOT NULL,
+    version bigint NOT NULL,
+    "timestamp" timestamp without time zone DEFAULT timezone('utc'::text, now()) NOT NULL,
+    data jsonb DEFAULT '{}'::jsonb NOT NULL
+);
 CREATE TABLE transactions (
     id character varying NOT NULL,
     "timestamp" timestamp without time zone NOT NULL,
-    data jsonb DEFAULT '{}'::jsonb NOT NULL
+    data jsonb DEFAULT '{}'::jsonb NOT NULL,
+    version bigint DEFAULT 1 NOT NULL
 );
+ALTER TABLE ONLY audit_log ALTER COLUMN id SET DEFAULT nextval('audit_log_id_seq'::regclass);
 ALTER TABLE ONLY lines ALTER COLUMN id SET DEFAULT nextval('lines_id_seq'::regclass);
+ALTER TABLE ONLY account_versions
+    ADD CONSTRAINT account_versions_pkey PRIMARY KEY (account_id, version);
 ALTER TABLE ONLY accounts
     ADD CONSTRAINT accounts_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY api_tokens
+    ADD CONSTRAINT api_tokens_pkey PRIMARY KEY (name);
+ALTER TABLE ONLY audit_log
+    ADD CONSTRAINT audit_log_pkey PRIMARY KEY (id);
 ALTER TABLE ONLY lines
     ADD CONSTRAINT lines_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY reversals
+    ADD CONSTRAINT reversals_pkey PRIMARY KEY (transaction_id);
+ALTER TABLE ONLY reversals
+    ADD CONSTRAINT reversals_reversal_id_key UNIQUE (reversal_id);
 ALTER TABLE ONLY schema_migrations
     ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
+ALTER TABLE ONLY transaction_versions
+    ADD CONSTRAINT transaction_versions_pkey PRIMARY KEY (transaction_id, version);
 ALTER TABLE ONLY transactions
     ADD CONSTRAINT transactions_pkey PRIMARY KEY (id);
+CREATE UNIQUE INDEX api_tokens_token_hash_idx ON api_tokens USING btree (token_hash);
+CREATE INDEX audit_log_entity_idx ON audit_log USING btree (entity_type, entity_id);
 CREATE INDEX accounts_data_idx ON accounts USING gin (data jsonb_path_ops);
 CREATE INDEX lines_account_id_idx ON lines USING btree (account_id);
+CREATE INDEX lines_account_id_pattern_idx ON lines USING btree (account_id varchar_pattern_ops);
 CREATE INDEX lines_transaction_id_idx ON lines USING btree (transaction_id);
 CREATE INDEX timestamp_idx ON transactions USING brin ("timestamp");
 CREATE INDEX transactions_data_idx ON transactions USING gin (data jsonb_path_ops);
 CREATE RULE "_RETURN" AS
     ON SELECT TO current_balances DO INSTEAD  SELECT accounts.id,
     accounts.data,
-    COALESCE(sum(lines.delta), (0)::numeric) AS balance
+    COALESCE(sum(lines.delta), (0)::numeric) AS balance,
+    accounts.version
    FROM (accounts
      LEFT JOIN lines ON (((accounts.id)::text = (lines.account_id)::text)))
   GROUP BY accounts.id;
+CREATE TRIGGER audit_log_append_only BEFORE DELETE OR UPDATE ON audit_log FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
+ALTER TABLE ONLY account_versions
+    ADD CONSTRAINT account_versions_account_id_fkey FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE;
 ALTER TABLE ONLY lines
     ADD CONSTRAINT lines_account_id_fkey FOREIGN KEY (account_id) REFERENCES accounts(id);
 ALTER TABLE ONLY lines
     ADD CONSTRAINT lines_txn_fkey FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+ALTER TABLE ONLY reversals
+    ADD CONSTRAINT reversals_reversal_id_fkey FOREIGN KEY (reversal_id) REFERENCES transactions(id) ON DELETE CASCADE;
+ALTER TABLE ONLY reversals
+    ADD CONSTRAINT reversals_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE;
+ALTER TABLE ONLY transaction_versions
+    ADD CONSTRAINT transaction_versions_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE;
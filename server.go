@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+)
+
+// listenAndServe runs the server in TLS if the certificate is set in `LEDGER_TLS_CERT_FILE`,
+// and verifies the client certificates if the CA bundle is set in `LEDGER_TLS_CLIENT_CA_FILE`.
+// Otherwise the server runs in plaintext.
+// The client certificates are verified only if given, so that the health checks don't need them,
+// and they are required by the authenticator of the APIs instead.
+func listenAndServe(addr string, handler http.Handler) error {
+	certFile := os.Getenv("LEDGER_TLS_CERT_FILE")
+	keyFile := os.Getenv("LEDGER_TLS_KEY_FILE")
+	clientCAFile := os.Getenv("LEDGER_TLS_CLIENT_CA_FILE")
+	if certFile == "" {
+		if clientCAFile != "" {
+			log.Fatal("Cannot verify the client certificates without TLS!! Please set LEDGER_TLS_CERT_FILE and LEDGER_TLS_KEY_FILE")
+		}
+		log.Println("Serving in plaintext. TLS certificate is not set")
+		return http.ListenAndServe(addr, handler)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCAFile != "" {
+		clientCAs, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			log.Fatal("Unable to read the client CA file:", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(clientCAs) {
+			log.Fatal("Unable to load any certificate from the client CA file")
+		}
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		log.Println("Verifying the client certificates")
+	}
+
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
+	log.Println("Serving in TLS")
+	return server.ListenAndServeTLS(certFile, keyFile)
+}